@@ -10,6 +10,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/gotracker/gotracker/internal/output"
+	"github.com/gotracker/gotracker/internal/output/device"
 	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
 )
 
@@ -31,7 +32,7 @@ var (
 	deviceListCmd = &cobra.Command{
 		Use:   "list",
 		Short: "List the available output devices",
-		Long:  `List the available output devices.`,
+		Long:  `List the available output devices, along with the formats each of them can play and what each of them can output to (such as the sinks of the pulseaudio device).`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var pmap []map[string]output.DeviceInfo
 			for k, v := range output.GetOutputDevices() {
@@ -54,7 +55,7 @@ var (
 				}
 				err = jw.Encode(list)
 			case "csv":
-				fieldOrder := []string{"device", "kind", "priority", "is_default"}
+				fieldOrder := []string{"device", "kind", "priority", "is_default", "sample_rates", "bits_per_sample", "channels", "description"}
 				cw := csv.NewWriter(os.Stdout)
 				if deviceListAddHeader {
 					if err = cw.Write(fieldOrder); err != nil {
@@ -93,17 +94,41 @@ func deviceListSerialized(pmap []map[string]output.DeviceInfo, recordFunc record
 				kind = "sound-card"
 			case deviceCommon.KindFile:
 				kind = "file-writer"
+			case deviceCommon.KindStream:
+				kind = "stream"
 			default:
 				kind = "unknown"
 			}
+			isDefault := (k == output.DefaultOutputDeviceName)
 			vals := make(map[string]any)
 			vals["device"] = k
 			vals["kind"] = kind
 			vals["priority"] = v.Priority
-			vals["is_default"] = (k == output.DefaultOutputDeviceName)
+			vals["is_default"] = isDefault
+			vals["sample_rates"] = v.Capabilities.SampleRatesString()
+			vals["bits_per_sample"] = v.Capabilities.BitsPerSampleString()
+			vals["channels"] = v.Capabilities.ChannelsString()
+			vals["description"] = ""
 			if err := recordFunc(vals); err != nil {
 				return err
 			}
+
+			// the device may not be able to list what it outputs to right now (e.g.: no sound server running)
+			targets, _ := device.ListTargets(k)
+			for _, t := range targets {
+				vals := make(map[string]any)
+				vals["device"] = k + ":" + t.Name
+				vals["kind"] = kind
+				vals["priority"] = v.Priority
+				vals["is_default"] = isDefault && t.IsDefault
+				vals["sample_rates"] = v.Capabilities.SampleRatesString()
+				vals["bits_per_sample"] = v.Capabilities.BitsPerSampleString()
+				vals["channels"] = v.Capabilities.ChannelsString()
+				vals["description"] = t.Description
+				if err := recordFunc(vals); err != nil {
+					return err
+				}
+			}
 		}
 	}
 	return nil
@@ -120,18 +145,22 @@ func deviceListHuman(pmap []map[string]output.DeviceInfo) error {
 
 	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
 	if deviceListAddHeader {
-		fmt.Fprintln(tw, "DEVICE\tKind\tPriority\tDefault?")
-		fmt.Fprintln(tw, "======\t====\t========\t========")
+		fmt.Fprintln(tw, "DEVICE\tKind\tPriority\tDefault?\tSample Rates\tBits\tChannels\tDescription")
+		fmt.Fprintln(tw, "======\t====\t========\t========\t============\t====\t========\t===========")
 	}
 	if err := deviceListSerialized(pmap, func(vals map[string]any) error {
 		name := vals["device"].(string)
 		kind := vals["kind"].(string)
 		priority := vals["priority"].(int)
+		sampleRates := vals["sample_rates"].(string)
+		bitsPerSample := vals["bits_per_sample"].(string)
+		channels := vals["channels"].(string)
+		description := vals["description"].(string)
 		var defaultStr string
 		if vals["is_default"].(bool) {
 			defaultStr = "*"
 		}
-		_, err := fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", name, kind, priority, defaultStr)
+		_, err := fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", name, kind, priority, defaultStr, sampleRates, bitsPerSample, channels, description)
 		return err
 	}); err != nil {
 		return err
@@ -0,0 +1,54 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gotracker/gotracker/internal/extract"
+)
+
+var (
+	extractOut  string = "."
+	extractType string = "wav"
+)
+
+func init() {
+	if flags := extractCmd.Flags(); flags != nil {
+		flags.StringVarP(&extractOut, "out", "o", extractOut, "directory to write the samples into")
+		flags.StringVarP(&extractType, "type", "t", extractType, fmt.Sprintf("type of file to write {%s}", strings.Join(extract.Types(), ", ")))
+	}
+
+	rootCmd.AddCommand(extractCmd)
+}
+
+var (
+	extractCmd = &cobra.Command{
+		Use:   "extract [flags] <file>",
+		Short: "Extract the samples of a song",
+		Long:  `Extract every sample of a song into its own audio file, keeping its name and loop points.`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filename := args[0]
+			samples, err := extract.Read(filename)
+			if err != nil {
+				return fmt.Errorf("%s: %w", filename, err)
+			}
+
+			if err := os.MkdirAll(extractOut, 0755); err != nil {
+				return err
+			}
+
+			for i := range samples {
+				out, err := extract.Write(extractOut, extractType, &samples[i])
+				if err != nil {
+					return err
+				}
+				fmt.Println(out)
+			}
+			return nil
+		},
+	}
+)
@@ -0,0 +1,124 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/gotracker/gotracker/internal/songinfo"
+)
+
+var (
+	infoFormat string = "human"
+)
+
+func init() {
+	if flags := infoCmd.Flags(); flags != nil {
+		flags.StringVarP(&infoFormat, "format", "f", infoFormat, "format of output {human, json, yaml}")
+	}
+
+	rootCmd.AddCommand(infoCmd)
+}
+
+var (
+	infoCmd = &cobra.Command{
+		Use:   "info [flags] <file> [file...]",
+		Short: "Display information about songs",
+		Long:  `Display information about songs, such as their format, instruments and estimated duration.`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var infos []*songinfo.Info
+			for _, filename := range args {
+				info, err := songinfo.Load(filename)
+				if err != nil {
+					return fmt.Errorf("%s: %w", filename, err)
+				}
+				infos = append(infos, info)
+			}
+
+			switch infoFormat {
+			case "json":
+				jw := json.NewEncoder(os.Stdout)
+				jw.SetIndent("", "  ")
+				return jw.Encode(infos)
+			case "yaml":
+				yw := yaml.NewEncoder(os.Stdout)
+				if err := yw.Encode(infos); err != nil {
+					return err
+				}
+				return yw.Close()
+			case "human":
+				for i, info := range infos {
+					if i > 0 {
+						fmt.Println()
+					}
+					if err := infoHuman(info); err != nil {
+						return err
+					}
+				}
+				return nil
+			default:
+				return fmt.Errorf("unsupported output format: %s", infoFormat)
+			}
+		},
+	}
+)
+
+func infoHuman(info *songinfo.Info) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "File:\t%s\n", info.Filename)
+	fmt.Fprintf(tw, "Title:\t%s\n", info.Title)
+	fmt.Fprintf(tw, "Format:\t%s\n", info.Format)
+	fmt.Fprintf(tw, "Tracker:\t%s\n", info.Tracker)
+	fmt.Fprintf(tw, "Orders:\t%d\n", info.Orders)
+	fmt.Fprintf(tw, "Patterns:\t%d\n", info.Patterns)
+	fmt.Fprintf(tw, "Channels:\t%d\n", info.Channels)
+	fmt.Fprintf(tw, "Tempo / BPM:\t%d / %d\n", info.InitialTempo, info.InitialBPM)
+	if info.Looping {
+		fmt.Fprintf(tw, "Duration:\t%s (loops forever)\n", info.Duration.Round(time.Millisecond))
+	} else {
+		fmt.Fprintf(tw, "Duration:\t%s\n", info.Duration.Round(time.Millisecond))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	infoHumanNames("Instruments", info.Instruments)
+	infoHumanNames("Samples", info.Samples)
+
+	if len(info.Positions) > 0 {
+		fmt.Println()
+		fmt.Println("Order timestamps:")
+		for _, pos := range info.Positions {
+			t := time.Duration(pos.Seconds * float64(time.Second))
+			fmt.Printf("  [%0.3d:%0.3d] %s\n", pos.Order, pos.Row, t.Round(time.Millisecond))
+		}
+	}
+
+	if info.Message != "" {
+		fmt.Println()
+		fmt.Println("Message:")
+		for _, line := range strings.Split(info.Message, "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	return nil
+}
+
+func infoHumanNames(title string, names []string) {
+	if len(names) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Printf("%s:\n", title)
+	for i, name := range names {
+		fmt.Printf("  %3d: %s\n", i+1, name)
+	}
+}
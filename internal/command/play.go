@@ -1,14 +1,17 @@
 package command
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/gotracker/gotracker/internal/config"
 	"github.com/gotracker/gotracker/internal/logging"
 	"github.com/gotracker/gotracker/internal/output"
+	"github.com/gotracker/gotracker/internal/output/device"
 	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
 	"github.com/gotracker/gotracker/internal/play"
 	"github.com/gotracker/gotracker/internal/playlist"
@@ -20,6 +23,8 @@ var playSettings = config.NewConfig(play.Settings{
 	NumPremixBuffers:    64,
 	ITLongChannelOutput: false,
 	ITEnableNNA:         true,
+	LimiterCeiling:      -1,
+	LimiterRelease:      50 * time.Millisecond,
 })
 
 var playOutputSettings = config.NewConfig(deviceCommon.Settings{
@@ -28,18 +33,25 @@ var playOutputSettings = config.NewConfig(deviceCommon.Settings{
 	BitsPerSample:    16,
 	StereoSeparation: 50, // 50%
 	Filepath:         "output.wav",
+	HTTPListen:       "localhost:8000",
+	Latency:          100 * time.Millisecond,
 })
 
 // flags
 type playFlagCfg struct {
-	LoopSong             bool `flag:"loop-song" env:"loop_song" f:"l" usage:"enable pattern loop (only works in single-song mode)"`
-	StartingOrder        int  `flag:"starting-order" env:"starting_order" f:"o" usage:"starting order (<0 = use song/format default)"`
-	StartingRow          int  `flag:"starting-row" env:"starting_row" f:"r" usage:"starting row (<0 = use song/format default)"`
-	Randomized           bool `flag:"random" env:"random" f:"R" usage:"randomize the playlist"`
-	StartingBPM          int  `flag:"bpm" env:"bpm" usage:"starting BPM (<0 = use song/format default)"`
-	StartingTempo        int  `flag:"tempo" env:"tempo" usage:"starting Tempo (ticks per row) (<0 = use song/format default)"`
-	LoopPlaylist         bool `pflag:"loop-playlist" env:"loop_playlist" pf:"L" usage:"enable playlist loop (only useful in multi-song mode)"`
-	DisableNativeSamples bool `pflag:"disable-native-samples" env:"disable_native_samples" usage:"disable preconversion of samples to native sampling format"`
+	LoopSong             bool          `flag:"loop-song" env:"loop_song" f:"l" usage:"enable pattern loop (only works in single-song mode)"`
+	StartingOrder        int           `flag:"starting-order" env:"starting_order" f:"o" usage:"starting order (<0 = use song/format default)"`
+	StartingRow          int           `flag:"starting-row" env:"starting_row" f:"r" usage:"starting row (<0 = use song/format default)"`
+	Randomized           bool          `flag:"random" env:"random" f:"R" usage:"randomize the playlist"`
+	StartingBPM          int           `flag:"bpm" env:"bpm" usage:"starting BPM (<0 = use song/format default)"`
+	StartingTempo        int           `flag:"tempo" env:"tempo" usage:"starting Tempo (ticks per row) (<0 = use song/format default)"`
+	Mute                 []int         `flag:"mute" env:"mute" usage:"channels (1-based) to mute"`
+	Solo                 []int         `flag:"solo" env:"solo" usage:"channels (1-based) to solo (all other channels are muted)"`
+	LoopPlaylist         bool          `pflag:"loop-playlist" env:"loop_playlist" pf:"L" usage:"enable playlist loop (only useful in multi-song mode)"`
+	Transition           string        `pflag:"transition" env:"transition" usage:"transition between songs (gapless, crossfade, silence; blank = none) (only useful in multi-song mode)"`
+	TransitionLength     time.Duration `pflag:"transition-length" env:"transition_length" usage:"length of the crossfade or silence transition"`
+	DisableNativeSamples bool          `pflag:"disable-native-samples" env:"disable_native_samples" usage:"disable preconversion of samples to native sampling format"`
+	DisableKeyboard      bool          `pflag:"disable-keyboard" env:"disable_keyboard" usage:"disable interactive keyboard controls"`
 	//DisablePreconvertSamples bool `pflag:"disable-preconvert-samples" env:"disable_preconvert_samples" usage:"disable preconversion of samples to 32-bit floats"`
 }
 
@@ -51,7 +63,10 @@ var playFlags = config.NewConfig(playFlagCfg{
 	StartingBPM:          -1,
 	StartingTempo:        -1,
 	LoopPlaylist:         false,
+	Transition:           "",
+	TransitionLength:     5 * time.Second,
 	DisableNativeSamples: false,
+	DisableKeyboard:      false,
 	//DisablePreconvertSamples: false,
 })
 
@@ -163,6 +178,8 @@ func getPlaylistFromArgList(args []string) (*playlist.Playlist, error) {
 		if cfg.StartingTempo >= 0 {
 			song.Tempo.Set(cfg.StartingTempo)
 		}
+		song.Mute = cfg.Mute
+		song.Solo = cfg.Solo
 		if len(args) == 1 {
 			if cfg.LoopSong {
 				song.Loop.Count = playlist.NewLoopForever()
@@ -173,6 +190,17 @@ func getPlaylistFromArgList(args []string) (*playlist.Playlist, error) {
 		pl.Add(song)
 	}
 
+	if cfg.Transition != "" {
+		mode := playlist.TransitionMode(cfg.Transition)
+		if !mode.IsValid() {
+			return nil, fmt.Errorf("unknown transition mode %q", cfg.Transition)
+		}
+		var t playlist.Transition
+		t.Mode.Set(mode)
+		t.Length.Set(cfg.TransitionLength)
+		pl.SetTransition(t)
+	}
+
 	pl.SetLooping(cfg.LoopPlaylist)
 	pl.SetRandomized(cfg.Randomized)
 	return pl, nil
@@ -180,9 +208,25 @@ func getPlaylistFromArgList(args []string) (*playlist.Playlist, error) {
 
 func playSongs(pl *playlist.Playlist) (bool, error) {
 	cfg := playFlags.Get()
+	outCfg := playOutputSettings.Get()
+
+	log := logger.Get()
+	if device.WritesToStdout(*outCfg) {
+		// keep the audio stream clean
+		log.Output = os.Stderr
+	}
 
 	var features []feature.Feature
 	features = append(features, feature.UseNativeSampleFormat(!cfg.DisableNativeSamples))
 
-	return play.Playlist(pl, features, playSettings.Get(), playOutputSettings.Get(), playDebugSettings.Get(), logger.Get())
+	var controls <-chan play.Control
+	if !cfg.DisableKeyboard {
+		kb, ch := openPlayKeyboard()
+		if kb != nil {
+			defer kb.Close()
+			controls = ch
+		}
+	}
+
+	return play.Playlist(pl, features, playSettings.Get(), outCfg, playDebugSettings.Get(), log, controls)
 }
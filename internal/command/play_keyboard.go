@@ -0,0 +1,70 @@
+package command
+
+import (
+	"os"
+
+	"github.com/gotracker/gotracker/internal/keyboard"
+	"github.com/gotracker/gotracker/internal/play"
+)
+
+var playKeyboardControls = map[rune]play.Control{
+	' ': play.ControlPauseToggle,
+	'n': play.ControlNext,
+	'N': play.ControlNext,
+	'p': play.ControlPrevious,
+	'P': play.ControlPrevious,
+	'q': play.ControlQuit,
+	'Q': play.ControlQuit,
+	'.': play.ControlSeekForward,
+	'>': play.ControlSeekForward,
+	',': play.ControlSeekBackward,
+	'<': play.ControlSeekBackward,
+}
+
+// playKeyboardChannels maps the number keys to the (0-based) channels they mute or solo
+var playKeyboardChannels = map[rune]int{
+	'1': 0,
+	'2': 1,
+	'3': 2,
+	'4': 3,
+	'5': 4,
+	'6': 5,
+	'7': 6,
+	'8': 7,
+	'9': 8,
+	'0': 9,
+}
+
+// openPlayKeyboard starts translating keypresses on stdin into playback controls
+// if stdin is not an interactive terminal, then the keyboard returned is nil
+func openPlayKeyboard() (*keyboard.Keyboard, <-chan play.Control) {
+	kb, err := keyboard.Open(os.Stdin)
+	if err != nil {
+		return nil, nil
+	}
+
+	controls := make(chan play.Control, 1)
+	go func() {
+		defer close(controls)
+		solo := false
+		for key := range kb.Keys() {
+			if ch, ok := playKeyboardChannels[key]; ok {
+				if solo {
+					controls <- play.ControlSoloChannel(ch)
+				} else {
+					controls <- play.ControlMuteChannel(ch)
+				}
+				solo = false
+				continue
+			}
+
+			// `s` followed by a channel number solos the channel
+			solo = (key == 's' || key == 'S')
+			if c, ok := playKeyboardControls[key]; ok {
+				controls <- c
+			}
+		}
+	}()
+
+	return kb, controls
+}
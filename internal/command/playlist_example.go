@@ -46,6 +46,9 @@ var (
 					Order: optional.NewValue(83),
 					Row:   optional.NewValue(56),
 				},
+				Fadeout: playlist.Fadeout{
+					Length: optional.NewValue(96),
+				},
 			})
 			pl.Add(playlist.Song{
 				Filepath: skavPath,
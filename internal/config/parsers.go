@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/exp/constraints"
 )
@@ -51,8 +52,12 @@ func setValueToField(f reflect.Value, val string) error {
 		var iv uint64
 		iv, err = strconv.ParseUint(val, 0, 0)
 		*v = uint(iv)
+	case *float64:
+		*v, err = strconv.ParseFloat(val, 64)
 	case *string:
 		*v = val
+	case *time.Duration:
+		*v, err = time.ParseDuration(val)
 	case *[]bool:
 		*v, err = parseCSVBoolArray(val)
 	case *[]int64:
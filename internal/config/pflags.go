@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -72,8 +73,12 @@ func (pflags) Update(cfg, param any) error {
 			fs.Uint8VarP(v, name, shorthand, *v, usage)
 		case *uint:
 			fs.UintVarP(v, name, shorthand, *v, usage)
+		case *float64:
+			fs.Float64VarP(v, name, shorthand, *v, usage)
 		case *string:
 			fs.StringVarP(v, name, shorthand, *v, usage)
+		case *time.Duration:
+			fs.DurationVarP(v, name, shorthand, *v, usage)
 		case *[]bool:
 			fs.BoolSliceVarP(v, name, shorthand, *v, usage)
 		case *[]int64:
@@ -0,0 +1,90 @@
+package dsp
+
+import (
+	"math"
+
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/mixing/volume"
+)
+
+const chorusName = "chorus"
+
+// chorus mixes each channel with a copy of itself on a slowly wavering delay,
+// with the wavering of each channel a quarter of a cycle behind the one before it
+type chorus struct {
+	mix   float64
+	delay float64 // in samples
+	depth float64 // in samples
+	step  float64 // the phase advance of the wavering, per sample
+	phase float64
+
+	buf [maxChannels][]float64
+	pos int
+}
+
+// newChorus returns a chorus
+//
+//	rate:  how quickly the delay wavers, in Hz (default 0.8)
+//	depth: how far the delay wavers, in milliseconds (default 3)
+//	delay: the shortest delay, in milliseconds (default 15)
+//	mix:   how much of the delayed copy is heard over the dry mix, from 0 to 1 (default 0.5)
+func newChorus(channels, sampleRate int, p *params) (bufferEffect, error) {
+	rate, err := p.number("rate", 0.8, 0.01, 20)
+	if err != nil {
+		return nil, err
+	}
+	depth, err := p.number("depth", 3, 0, 50)
+	if err != nil {
+		return nil, err
+	}
+	delay, err := p.number("delay", 15, 0, 100)
+	if err != nil {
+		return nil, err
+	}
+	mix, err := p.number("mix", 0.5, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	samplesPerMs := float64(sampleRate) / 1000
+	c := chorus{
+		mix:   mix,
+		delay: delay * samplesPerMs,
+		depth: depth * samplesPerMs,
+		step:  2 * math.Pi * rate / float64(sampleRate),
+	}
+	length := int(math.Ceil(c.delay+c.depth)) + 2
+	for ch := 0; ch < channels; ch++ {
+		c.buf[ch] = make([]float64, length)
+	}
+	return &c, nil
+}
+
+func (c *chorus) process(data mixing.MixBuffer) {
+	for i := range data {
+		frame := &data[i]
+		for ch := 0; ch < frame.Channels; ch++ {
+			buf := c.buf[ch]
+			x := float64(frame.StaticMatrix[ch])
+			buf[c.pos] = x
+
+			// read the delayed copy from between two of the samples held
+			lfo := (1 + math.Sin(c.phase+float64(ch)*math.Pi/2)) / 2
+			back := c.delay + c.depth*lfo
+			whole := math.Floor(back)
+			frac := back - whole
+			n := len(buf)
+			a := buf[(c.pos-int(whole)+n)%n]
+			b := buf[(c.pos-int(whole)-1+n)%n]
+			delayed := a + (b-a)*frac
+
+			frame.StaticMatrix[ch] = volume.Volume(x*(1-c.mix) + delayed*c.mix)
+		}
+		c.pos = (c.pos + 1) % len(c.buf[0])
+		c.phase = math.Mod(c.phase+c.step, 2*math.Pi)
+	}
+}
+
+func init() {
+	effectMap[chorusName] = newChorus
+}
@@ -0,0 +1,154 @@
+// Package dsp colours the mix on its way to the output device with a chain of effects
+package dsp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/gotracker/playback/mixing"
+	playbackOutput "github.com/gotracker/playback/output"
+	"gopkg.in/yaml.v2"
+
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+)
+
+const (
+	// specSeparator separates the effects of a chain (e.g.: `eq:freq=80:gain=3,reverb`)
+	specSeparator = ","
+	// specOptionSeparator separates an effect's name from its parameters (and the parameters from each other)
+	specOptionSeparator = ":"
+)
+
+// Effect processes the mix, one premix at a time, in the order it is to be heard
+type Effect interface {
+	Process(premix *playbackOutput.PremixData) *playbackOutput.PremixData
+}
+
+// bufferEffect is an effect that works on the mix once it's been flattened down to already-panned samples
+type bufferEffect interface {
+	// process processes `data` in place
+	process(data mixing.MixBuffer)
+}
+
+// flatEffect runs a buffer effect over the flattened mix
+type flatEffect struct {
+	channels int
+	effect   bufferEffect
+}
+
+// Process flattens `premix` and runs the effect over it, carrying its tracker channels along as they were
+func (e flatEffect) Process(premix *playbackOutput.PremixData) *playbackOutput.PremixData {
+	data := Flatten(e.channels, premix)
+	e.effect.process(data)
+	return deviceCommon.WithTrackerChannels(NewFlatPremix(e.channels, data, premix.Userdata), premix)
+}
+
+type effectFactory func(channels, sampleRate int, p *params) (bufferEffect, error)
+
+var effectMap = make(map[string]effectFactory)
+
+// GetEffectNames returns the names of the built-in effects
+func GetEffectNames() []string {
+	names := make([]string, 0, len(effectMap))
+	for name := range effectMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Spec names an effect of a chain, along with its parameters
+type Spec struct {
+	Effect string
+	Params map[string]string
+}
+
+// UnmarshalYAML reads a spec from a mapping of the effect name (under `effect`) and its parameters
+func (s *Spec) UnmarshalYAML(unmarshal func(any) error) error {
+	var m map[string]string
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	s.Effect = m["effect"]
+	delete(m, "effect")
+	s.Params = m
+	return nil
+}
+
+// ParseSpecs parses a chain of effects, e.g.: `eq:freq=80:gain=3,widen:width=1.5,reverb:mix=0.2`
+func ParseSpecs(chain string) ([]Spec, error) {
+	var specs []Spec
+	for _, entry := range strings.Split(chain, specSeparator) {
+		parts := strings.Split(entry, specOptionSeparator)
+		spec := Spec{
+			Effect: strings.TrimSpace(parts[0]),
+			Params: make(map[string]string),
+		}
+		for _, part := range parts[1:] {
+			key, value, ok := strings.Cut(part, "=")
+			if !ok {
+				return nil, fmt.Errorf("effect %q: parameter %q has no value", spec.Effect, part)
+			}
+			spec.Params[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// ReadYAML reads a chain of effects from a list of specs, e.g.:
+//
+//   - effect: eq
+//     freq: 80
+//     gain: 3
+//   - effect: reverb
+//     mix: 0.2
+func ReadYAML(r io.Reader) ([]Spec, error) {
+	var specs []Spec
+	if err := yaml.NewDecoder(r).Decode(&specs); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// Chain runs the mix through a series of effects, in order
+type Chain []Effect
+
+// NewChain builds the effects named in `specs` for audio of `channels` channels at `sampleRate`
+func NewChain(specs []Spec, channels, sampleRate int) (Chain, error) {
+	var c Chain
+	for _, spec := range specs {
+		factory, ok := effectMap[spec.Effect]
+		if !ok {
+			return nil, fmt.Errorf("unknown effect %q (expected one of: %s)", spec.Effect, strings.Join(GetEffectNames(), ", "))
+		}
+		p := newParams(spec.Params)
+		effect, err := factory(channels, sampleRate, p)
+		if err == nil {
+			err = p.checkUnused()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("effect %q: %w", spec.Effect, err)
+		}
+		c = append(c, flatEffect{
+			channels: channels,
+			effect:   effect,
+		})
+	}
+	return c, nil
+}
+
+// Process runs `premix` through the effects of the chain.
+// Premixes without any audio (such as the details of a song about to start) are passed along untouched.
+func (c Chain) Process(premix *playbackOutput.PremixData) *playbackOutput.PremixData {
+	if premix.SamplesLen == 0 {
+		return premix
+	}
+	for _, effect := range c {
+		premix = effect.Process(premix)
+	}
+	return premix
+}
@@ -0,0 +1,51 @@
+package dsp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSpecsFromFlagAndYAMLMatch(t *testing.T) {
+	fromFlag, err := ParseSpecs("eq:type=lowshelf:freq=80:gain=3,reverb:mix=0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromYAML, err := ReadYAML(strings.NewReader(`
+- effect: eq
+  type: lowshelf
+  freq: 80
+  gain: 3
+- effect: reverb
+  mix: 0.2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fromFlag, fromYAML) {
+		t.Fatalf("expected the chains to match:\n%#v\n%#v", fromFlag, fromYAML)
+	}
+
+	if _, err := NewChain(fromFlag, 2, 44100); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewChainRejectsBadSpecs(t *testing.T) {
+	for _, chain := range []string{
+		"flanger",
+		"eq:gian=3",
+		"eq:type=notch",
+		"reverb:mix=2",
+	} {
+		specs, err := ParseSpecs(chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewChain(specs, 2, 44100); err == nil {
+			t.Errorf("expected %q to be rejected", chain)
+		}
+	}
+}
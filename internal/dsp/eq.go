@@ -0,0 +1,95 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/mixing/volume"
+)
+
+const eqName = "eq"
+
+// biquad is a second-order IIR filter, run over each channel of the mix separately
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+	x1, x2     [maxChannels]float64
+	y1, y2     [maxChannels]float64
+}
+
+func (f *biquad) process(data mixing.MixBuffer) {
+	for i := range data {
+		frame := &data[i]
+		for c := 0; c < frame.Channels; c++ {
+			x := float64(frame.StaticMatrix[c])
+			y := f.b0*x + f.b1*f.x1[c] + f.b2*f.x2[c] - f.a1*f.y1[c] - f.a2*f.y2[c]
+			f.x1[c], f.x2[c] = x, f.x1[c]
+			f.y1[c], f.y2[c] = y, f.y1[c]
+			frame.StaticMatrix[c] = volume.Volume(y)
+		}
+	}
+}
+
+// newEQ returns one band of a parametric equalizer - a peaking, shelving or pass filter
+// (as per the "Audio EQ Cookbook" by Robert Bristow-Johnson)
+//
+//	type: peak, lowshelf, highshelf, lowpass or highpass (default peak)
+//	freq: the centre (or corner) frequency in Hz (default 1000)
+//	gain: the boost (or cut) in dB, for the peak and shelf filters (default 0)
+//	q:    the sharpness of the filter (default 0.707)
+func newEQ(channels, sampleRate int, p *params) (bufferEffect, error) {
+	kind := p.text("type", "peak")
+	freq, err := p.number("freq", 1000, 1, float64(sampleRate)/2)
+	if err != nil {
+		return nil, err
+	}
+	gain, err := p.number("gain", 0, -48, 48)
+	if err != nil {
+		return nil, err
+	}
+	q, err := p.number("q", math.Sqrt2/2, 0.01, 100)
+	if err != nil {
+		return nil, err
+	}
+
+	a := math.Pow(10, gain/40)
+	w0 := 2 * math.Pi * freq / float64(sampleRate)
+	cosw0, sinw0 := math.Cos(w0), math.Sin(w0)
+	alpha := sinw0 / (2 * q)
+
+	var b0, b1, b2, a0, a1, a2 float64
+	switch kind {
+	case "peak":
+		b0, b1, b2 = 1+alpha*a, -2*cosw0, 1-alpha*a
+		a0, a1, a2 = 1+alpha/a, -2*cosw0, 1-alpha/a
+	case "lowshelf":
+		sq := 2 * math.Sqrt(a) * alpha
+		b0, b1, b2 = a*((a+1)-(a-1)*cosw0+sq), 2*a*((a-1)-(a+1)*cosw0), a*((a+1)-(a-1)*cosw0-sq)
+		a0, a1, a2 = (a+1)+(a-1)*cosw0+sq, -2*((a-1)+(a+1)*cosw0), (a+1)+(a-1)*cosw0-sq
+	case "highshelf":
+		sq := 2 * math.Sqrt(a) * alpha
+		b0, b1, b2 = a*((a+1)+(a-1)*cosw0+sq), -2*a*((a-1)+(a+1)*cosw0), a*((a+1)+(a-1)*cosw0-sq)
+		a0, a1, a2 = (a+1)-(a-1)*cosw0+sq, 2*((a-1)-(a+1)*cosw0), (a+1)-(a-1)*cosw0-sq
+	case "lowpass":
+		b0, b1, b2 = (1-cosw0)/2, 1-cosw0, (1-cosw0)/2
+		a0, a1, a2 = 1+alpha, -2*cosw0, 1-alpha
+	case "highpass":
+		b0, b1, b2 = (1+cosw0)/2, -(1 + cosw0), (1+cosw0)/2
+		a0, a1, a2 = 1+alpha, -2*cosw0, 1-alpha
+	default:
+		return nil, fmt.Errorf("unknown type %q (expected one of: peak, lowshelf, highshelf, lowpass, highpass)", kind)
+	}
+
+	return &biquad{
+		b0: b0 / a0,
+		b1: b1 / a0,
+		b2: b2 / a0,
+		a1: a1 / a0,
+		a2: a2 / a0,
+	}, nil
+}
+
+func init() {
+	effectMap[eqName] = newEQ
+}
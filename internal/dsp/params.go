@@ -0,0 +1,62 @@
+package dsp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// params are the parameters of an effect, keeping track of which of them have been used
+type params struct {
+	values map[string]string
+	used   map[string]struct{}
+}
+
+func newParams(values map[string]string) *params {
+	return &params{
+		values: values,
+		used:   make(map[string]struct{}),
+	}
+}
+
+// text returns the parameter `key`, or `def` if it isn't set
+func (p *params) text(key, def string) string {
+	p.used[key] = struct{}{}
+	if v, ok := p.values[key]; ok {
+		return v
+	}
+	return def
+}
+
+// number returns the parameter `key` as a number between `lo` and `hi`, or `def` if it isn't set
+func (p *params) number(key string, def, lo, hi float64) (float64, error) {
+	p.used[key] = struct{}{}
+	s, ok := p.values[key]
+	if !ok {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", key, s)
+	}
+	if v < lo || v > hi {
+		return 0, fmt.Errorf("%s %v is out of range (%v to %v)", key, v, lo, hi)
+	}
+	return v, nil
+}
+
+// checkUnused returns an error naming any parameters that weren't used
+func (p *params) checkUnused() error {
+	var unused []string
+	for key := range p.values {
+		if _, ok := p.used[key]; !ok {
+			unused = append(unused, key)
+		}
+	}
+	if len(unused) == 0 {
+		return nil
+	}
+	sort.Strings(unused)
+	return fmt.Errorf("unknown parameter(s): %s", strings.Join(unused, ", "))
+}
@@ -0,0 +1,80 @@
+package dsp
+
+import (
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/mixing/volume"
+	playbackOutput "github.com/gotracker/playback/output"
+)
+
+// the most channels a mix can have
+const maxChannels = len(volume.StaticMatrix{})
+
+// passthroughPan is a pan mixer for data that has already been panned
+type passthroughPan struct {
+	channels int
+}
+
+func (p passthroughPan) ApplyToMatrix(mtx volume.Matrix) volume.Matrix {
+	return mtx
+}
+
+func (p passthroughPan) Apply(vol volume.Volume) volume.Matrix {
+	mtx := volume.Matrix{
+		Channels: p.channels,
+	}
+	for i := 0; i < p.channels; i++ {
+		mtx.StaticMatrix[i] = vol
+	}
+	return mtx
+}
+
+// Flatten mixes all the channels of a premix down to a single buffer of already-panned data
+func Flatten(channels int, premix *playbackOutput.PremixData) mixing.MixBuffer {
+	data := NewSilence(channels, premix.SamplesLen)
+	for _, rdata := range premix.Data {
+		for i := range rdata {
+			// the tracker channels may be output again later on (e.g.: as stems), so they're only finished off once
+			if rdata[i].Flush != nil {
+				rdata[i].Flush()
+				rdata[i].Flush = nil
+			}
+			if cdata := rdata[i]; len(cdata.Data) > 0 {
+				data.Add(cdata.Pos, cdata.Data, cdata.PanMatrix.Apply(cdata.Volume))
+			}
+		}
+	}
+	for i := range data {
+		data[i] = data[i].Apply(premix.MixerVolume)
+	}
+	return data
+}
+
+// NewSilence returns a buffer of `samples` samples of silence
+func NewSilence(channels, samples int) mixing.MixBuffer {
+	data := make(mixing.MixBuffer, samples)
+	for i := range data {
+		data[i].Channels = channels
+	}
+	return data
+}
+
+// NewFlatPremix returns premix data for a buffer of already-panned data
+func NewFlatPremix(channels int, data mixing.MixBuffer, userdata any) *playbackOutput.PremixData {
+	return &playbackOutput.PremixData{
+		SamplesLen: len(data),
+		Data: []mixing.ChannelData{
+			// the already-panned data can't be told apart by tracker channel
+			nil,
+			{
+				mixing.Data{
+					Data:       data,
+					PanMatrix:  passthroughPan{channels: channels},
+					Volume:     volume.Volume(1),
+					SamplesLen: len(data),
+				},
+			},
+		},
+		MixerVolume: volume.Volume(1),
+		Userdata:    userdata,
+	}
+}
@@ -0,0 +1,148 @@
+package dsp
+
+import (
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/mixing/volume"
+)
+
+const (
+	reverbName = "reverb"
+
+	// the tuning comes from Freeverb, by Jezar at Dreampoint (the delays are in samples at 44.1kHz)
+	reverbStereoSpread = 23
+	reverbInputGain    = 0.015
+	reverbWetScale     = 3
+	reverbAllpassGain  = 0.5
+)
+
+var (
+	reverbCombTuning    = []int{1116, 1188, 1277, 1356, 1422, 1491, 1557, 1617}
+	reverbAllpassTuning = []int{556, 441, 341, 225}
+)
+
+// reverb is a Schroeder-Moorer reverberator: a bank of damped comb filters feeding a series of allpass filters
+// for each channel, with the delays spread out a little from one channel to the next
+type reverb struct {
+	dry, wet1, wet2 float64
+	channels        int
+	combs           [maxChannels][]reverbComb
+	allpasses       [maxChannels][]reverbAllpass
+}
+
+type reverbComb struct {
+	buf      []float64
+	pos      int
+	feedback float64
+	damp     float64
+	filtered float64
+}
+
+func (c *reverbComb) process(x float64) float64 {
+	y := c.buf[c.pos]
+	c.filtered = y*(1-c.damp) + c.filtered*c.damp
+	c.buf[c.pos] = x + c.filtered*c.feedback
+	c.pos = (c.pos + 1) % len(c.buf)
+	return y
+}
+
+type reverbAllpass struct {
+	buf []float64
+	pos int
+}
+
+func (a *reverbAllpass) process(x float64) float64 {
+	delayed := a.buf[a.pos]
+	a.buf[a.pos] = x + delayed*reverbAllpassGain
+	a.pos = (a.pos + 1) % len(a.buf)
+	return delayed - x
+}
+
+// newReverb returns a reverb
+//
+//	room:  the size of the room, from 0 to 1 (default 0.5)
+//	damp:  how much the walls soak up the high end, from 0 to 1 (default 0.5)
+//	mix:   how much of the reverb is heard over the dry mix, from 0 to 1 (default 0.25)
+//	width: how far the reverb spreads between the channels, from 0 to 1 (default 1)
+func newReverb(channels, sampleRate int, p *params) (bufferEffect, error) {
+	room, err := p.number("room", 0.5, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	damp, err := p.number("damp", 0.5, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	mix, err := p.number("mix", 0.25, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	width, err := p.number("width", 1, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	wet := mix * reverbWetScale
+	r := reverb{
+		dry:      1 - mix,
+		wet1:     wet * (width/2 + 0.5),
+		wet2:     wet * (1 - width) / 2,
+		channels: channels,
+	}
+	scale := func(tuning, c int) int {
+		return max((tuning+c*reverbStereoSpread)*sampleRate/44100, 1)
+	}
+	for c := 0; c < channels; c++ {
+		for _, tuning := range reverbCombTuning {
+			r.combs[c] = append(r.combs[c], reverbComb{
+				buf:      make([]float64, scale(tuning, c)),
+				feedback: room*0.28 + 0.7,
+				damp:     damp * 0.4,
+			})
+		}
+		for _, tuning := range reverbAllpassTuning {
+			r.allpasses[c] = append(r.allpasses[c], reverbAllpass{
+				buf: make([]float64, scale(tuning, c)),
+			})
+		}
+	}
+	return &r, nil
+}
+
+func (r *reverb) process(data mixing.MixBuffer) {
+	var out [maxChannels]float64
+	for i := range data {
+		frame := &data[i]
+
+		// every channel's reverb is fed from the same mono mix
+		var in float64
+		for c := 0; c < frame.Channels; c++ {
+			in += float64(frame.StaticMatrix[c])
+		}
+		in *= reverbInputGain
+
+		for c := 0; c < r.channels; c++ {
+			var y float64
+			for j := range r.combs[c] {
+				y += r.combs[c][j].process(in)
+			}
+			for j := range r.allpasses[c] {
+				y = r.allpasses[c][j].process(y)
+			}
+			out[c] = y
+		}
+
+		for c := 0; c < frame.Channels; c++ {
+			// each channel is paired up with its neighbour (left with right, and so on) for the width
+			partner := c ^ 1
+			if partner >= r.channels {
+				partner = c
+			}
+			wet := out[c]*r.wet1 + out[partner]*r.wet2
+			frame.StaticMatrix[c] = volume.Volume(float64(frame.StaticMatrix[c])*r.dry + wet)
+		}
+	}
+}
+
+func init() {
+	effectMap[reverbName] = newReverb
+}
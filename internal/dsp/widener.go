@@ -0,0 +1,49 @@
+package dsp
+
+import (
+	"errors"
+
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/mixing/volume"
+)
+
+const widenerName = "widen"
+
+// widener spreads each pair of channels (left and right, and so on) further apart - or draws them closer
+// together - by scaling the difference between them (their "side") against what they share (their "mid")
+type widener struct {
+	width float64
+}
+
+// newWidener returns a stereo widener
+//
+//	width: 0 for mono, 1 to leave the channels as they are, and up to 4 for wider (default 1.5)
+func newWidener(channels, sampleRate int, p *params) (bufferEffect, error) {
+	if channels < 2 {
+		return nil, errors.New("needs at least 2 channels")
+	}
+	width, err := p.number("width", 1.5, 0, 4)
+	if err != nil {
+		return nil, err
+	}
+	return &widener{
+		width: width,
+	}, nil
+}
+
+func (w *widener) process(data mixing.MixBuffer) {
+	for i := range data {
+		frame := &data[i]
+		for c := 0; c+1 < frame.Channels; c += 2 {
+			l, r := float64(frame.StaticMatrix[c]), float64(frame.StaticMatrix[c+1])
+			mid := (l + r) / 2
+			side := (l - r) / 2 * w.width
+			frame.StaticMatrix[c] = volume.Volume(mid + side)
+			frame.StaticMatrix[c+1] = volume.Volume(mid - side)
+		}
+	}
+}
+
+func init() {
+	effectMap[widenerName] = newWidener
+}
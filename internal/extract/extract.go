@@ -0,0 +1,142 @@
+package extract
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gotracker/gotracker/internal/songinfo"
+)
+
+// Sample is a single sample (waveform) of a song, decoded into signed values
+type Sample struct {
+	Number        int // 1-based, in the order they are listed by the `info` command
+	Name          string
+	SampleRate    int // the rate at which the sample plays at middle C
+	BitsPerSample int
+	Data          [][]int32 // per-channel sample values
+	Loops         []Loop
+}
+
+// Loop is a loop of a sample, in sample frames
+type Loop struct {
+	Begin    int
+	End      int // exclusive
+	PingPong bool
+	Sustain  bool // only loops while the note is held
+}
+
+// Len returns the number of frames in the sample
+func (s Sample) Len() int {
+	if len(s.Data) == 0 {
+		return 0
+	}
+	return len(s.Data[0])
+}
+
+type sampleReader func(data []byte) ([]Sample, error)
+
+// Read reads every non-empty sample of the song in `filename`
+func Read(filename string) ([]Sample, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader sampleReader
+	switch songinfo.DetectFormat(data) {
+	case "IT":
+		reader = readITSamples
+	case "XM":
+		reader = readXMSamples
+	case "S3M":
+		reader = readS3MSamples
+	default:
+		reader = readMODSamples
+	}
+
+	samples, err := reader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Sample
+	for _, s := range samples {
+		if s.Len() == 0 {
+			continue
+		}
+		s.Loops = validLoops(s.Loops, s.Len())
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// validLoops drops any loops that do not fit within a sample of `length` frames
+func validLoops(loops []Loop, length int) []Loop {
+	var out []Loop
+	for _, l := range loops {
+		if l.End > length {
+			l.End = length
+		}
+		if l.Begin < 0 || l.Begin >= l.End {
+			continue
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+type sampleWriter func(filename string, s *Sample) error
+
+var (
+	sampleWriterMap = map[string]sampleWriter{
+		"wav": writeWavSample,
+	}
+)
+
+// Types returns the file types that samples can be written as
+func Types() []string {
+	var types []string
+	for t := range sampleWriterMap {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Write writes the sample `s` into the directory `dir` as a file of type `fileType`,
+// returning the name of the file written
+func Write(dir string, fileType string, s *Sample) (string, error) {
+	writer, ok := sampleWriterMap[fileType]
+	if !ok {
+		return "", fmt.Errorf("unsupported file type: %s", fileType)
+	}
+
+	filename := filepath.Join(dir, sampleFilename(s)+"."+fileType)
+	if err := writer(filename, s); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// sampleFilename returns a filename (without extension) for the sample, made up of its number and name
+func sampleFilename(s *Sample) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r < ' ' || r == 0x7F:
+			return -1
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			return '_'
+		default:
+			return r
+		}
+	}, s.Name)
+	name = strings.Trim(name, " .")
+
+	if name == "" {
+		return fmt.Sprintf("%03d", s.Number)
+	}
+	return fmt.Sprintf("%03d - %s", s.Number, name)
+}
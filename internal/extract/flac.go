@@ -0,0 +1,113 @@
+//go:build flac
+// +build flac
+
+package extract
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+const flacBlockSize = 4096
+
+func writeFlacSample(filename string, s *Sample) error {
+	var channels frame.Channels
+	switch len(s.Data) {
+	case 1:
+		channels = frame.ChannelsMono
+	case 2:
+		channels = frame.ChannelsLR
+	default:
+		return errors.New("unsupported channel count")
+	}
+
+	si := &meta.StreamInfo{
+		BlockSizeMin:  flacBlockSize,
+		BlockSizeMax:  flacBlockSize,
+		SampleRate:    uint32(s.SampleRate),
+		NChannels:     uint8(len(s.Data)),
+		BitsPerSample: uint8(s.BitsPerSample),
+	}
+
+	// FLAC has no standard place for loop points, so they go in the commonly-used LOOPSTART and LOOPLENGTH tags
+	comment := &meta.VorbisComment{
+		Vendor: "gotracker",
+	}
+	if s.Name != "" {
+		comment.Tags = append(comment.Tags, [2]string{"TITLE", s.Name})
+	}
+	if len(s.Loops) > 0 {
+		l := s.Loops[0]
+		comment.Tags = append(comment.Tags,
+			[2]string{"LOOPSTART", strconv.Itoa(l.Begin)},
+			[2]string{"LOOPLENGTH", strconv.Itoa(l.End - l.Begin)},
+		)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	// the encoder closes the file, after going back to fill in the stream info
+	enc, err := flac.NewEncoder(f, si, &meta.Block{
+		Header: meta.Header{
+			Type:   meta.TypeVorbisComment,
+			Length: vorbisCommentLength(comment),
+		},
+		Body: comment,
+	})
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	for pos := 0; pos < s.Len(); pos += flacBlockSize {
+		n := min(flacBlockSize, s.Len()-pos)
+		subframes := make([]*frame.Subframe, len(s.Data))
+		for c, values := range s.Data {
+			subframes[c] = &frame.Subframe{
+				SubHeader: frame.SubHeader{
+					Pred: frame.PredVerbatim,
+				},
+				Samples:  values[pos : pos+n],
+				NSamples: n,
+			}
+		}
+
+		fr := &frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         uint16(n),
+				SampleRate:        uint32(s.SampleRate),
+				Channels:          channels,
+				BitsPerSample:     uint8(s.BitsPerSample),
+			},
+			Subframes: subframes,
+		}
+		if err := enc.WriteFrame(fr); err != nil {
+			enc.Close()
+			return err
+		}
+	}
+
+	return enc.Close()
+}
+
+// vorbisCommentLength returns the encoded size of `comment`, which the encoder needs up front
+func vorbisCommentLength(comment *meta.VorbisComment) int64 {
+	n := 4 + len(comment.Vendor) + 4
+	for _, tag := range comment.Tags {
+		n += 4 + len(tag[0]) + 1 + len(tag[1])
+	}
+	return int64(n)
+}
+
+func init() {
+	sampleWriterMap["flac"] = writeFlacSample
+}
@@ -0,0 +1,115 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/gotracker/goaudiofile/music/tracked/it"
+
+	"github.com/gotracker/gotracker/internal/songinfo"
+)
+
+func readITSamples(data []byte) ([]Sample, error) {
+	f, err := it.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []Sample
+	for i, fs := range f.Samples {
+		si := &fs.Header
+		s := Sample{
+			Number:        i + 1,
+			Name:          songinfo.CleanName(si.GetName()),
+			SampleRate:    int(si.C5Speed),
+			BitsPerSample: 8,
+		}
+		if s.SampleRate == 0 {
+			s.SampleRate = 8363
+		}
+
+		if si.Flags.DoesSampleExist() {
+			format := pcmFormat{
+				channels: 1,
+				is16Bit:  si.Flags.Is16Bit(),
+				signed:   si.ConvertFlags.IsSignedSamples(),
+				order:    binary.LittleEndian,
+			}
+			if si.Flags.IsStereo() {
+				format.channels = 2
+			}
+			if format.is16Bit {
+				s.BitsPerSample = 16
+			}
+			if si.ConvertFlags.IsBigEndian() {
+				format.order = binary.BigEndian
+			}
+
+			if si.Flags.IsCompressed() {
+				s.Data, err = decompressITSample(fs.Data, int(si.Length), format, si.ConvertFlags.IsSampleDelta())
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				s.Data = decodePCM(fs.Data, int(si.Length), format)
+				if si.ConvertFlags.IsSampleDelta() {
+					for _, values := range s.Data {
+						deltaDecode(values, format.is16Bit)
+					}
+				}
+			}
+		}
+
+		if si.Flags.IsLoopEnabled() {
+			s.Loops = append(s.Loops, Loop{
+				Begin:    int(si.LoopBegin),
+				End:      int(si.LoopEnd),
+				PingPong: si.Flags.IsLoopPingPong(),
+			})
+		}
+		if si.Flags.IsSustainLoopEnabled() {
+			s.Loops = append(s.Loops, Loop{
+				Begin:    int(si.SustainLoopBegin),
+				End:      int(si.SustainLoopEnd),
+				PingPong: si.Flags.IsSustainLoopPingPong(),
+				Sustain:  true,
+			})
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+// decompressITSample decodes IT 2.14 compressed sample data.
+// The data is truncated to the uncompressed length of the sample when it is loaded, which is
+// almost always more than enough; whatever could not be decoded is left as silence.
+func decompressITSample(data []byte, length int, format pcmFormat, it215 bool) ([][]int32, error) {
+	r := it214Reader{
+		data:  data,
+		is16:  format.is16Bit,
+		it215: it215,
+	}
+
+	out := make([][]int32, format.channels)
+	for c := range out {
+		values, err := r.decompress(length)
+		if err != nil && err != errIT214Truncated {
+			return nil, err
+		}
+		out[c] = append(values, make([]int32, length-len(values))...)
+	}
+	return out, nil
+}
+
+// deltaDecode converts delta-encoded values into sample values, wrapping them like the original tracker would
+func deltaDecode(values []int32, is16Bit bool) {
+	var old int32
+	for i, v := range values {
+		if is16Bit {
+			old = int32(int16(old + v))
+		} else {
+			old = int32(int8(old + v))
+		}
+		values[i] = old
+	}
+}
@@ -0,0 +1,152 @@
+package extract
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errIT214Truncated = errors.New("compressed sample data is truncated")
+
+// it214Reader reads IT 2.14 compressed sample data, one channel at a time
+type it214Reader struct {
+	data   []byte
+	is16   bool
+	it215  bool // values are double-delta encoded (IT 2.15)
+	bitbuf uint32
+	bitnum uint32
+	block  []byte
+}
+
+// decompress decodes `length` values of a single channel
+func (r *it214Reader) decompress(length int) ([]int32, error) {
+	var (
+		blockLen  = 0x8000
+		maxWidth  = uint8(9)
+		widthBits = int8(3)
+	)
+	if r.is16 {
+		blockLen = 0x4000
+		maxWidth = 17
+		widthBits = 4
+	}
+
+	out := make([]int32, 0, length)
+	for len(out) < length {
+		// each block is a word holding its compressed size, followed by the compressed data
+		if len(r.data) < 2 {
+			return out, errIT214Truncated
+		}
+		clen := int(binary.LittleEndian.Uint16(r.data))
+		r.data = r.data[2:]
+		if clen > len(r.data) {
+			clen = len(r.data)
+		}
+		r.block, r.data = r.data[:clen], r.data[clen:]
+		r.bitbuf, r.bitnum = 0, 0
+
+		blkpos := 0
+		blklen := min(blockLen, length-len(out))
+		width := maxWidth
+		var d1, d2 int32
+		for blkpos < blklen {
+			if width == 0 || width > maxWidth {
+				return out, errors.New("illegal bit width in compressed sample data")
+			}
+			value, ok := r.readBits(int8(width))
+			if !ok {
+				return out, errIT214Truncated
+			}
+
+			switch {
+			case width < 7:
+				// method 1 (1-6 bits): "100..." changes the width
+				if value == 1<<(width-1) {
+					v, ok := r.readBits(widthBits)
+					if !ok {
+						return out, errIT214Truncated
+					}
+					width = nextIT214Width(uint8(v+1), width)
+					continue
+				}
+			case width < maxWidth:
+				// method 2 (7-8 or 7-16 bits): values around the top of the range change the width
+				var border uint32
+				if r.is16 {
+					border = (0xFFFF >> (17 - width)) - 8
+				} else {
+					border = (0xFF >> (9 - width)) - 4
+				}
+				if value > border && value <= border+uint32(maxWidth-1) {
+					width = nextIT214Width(uint8(value-border), width)
+					continue
+				}
+			default:
+				// method 3 (9 or 17 bits): the top bit being set changes the width
+				if value&(1<<(maxWidth-1)) != 0 {
+					width = uint8(value+1) & 0xFF
+					continue
+				}
+			}
+
+			// expand the value to a signed delta
+			var v int32
+			if r.is16 {
+				if width < 16 {
+					shift := 16 - width
+					v = int32(int16(value<<shift) >> shift)
+				} else {
+					v = int32(int16(value))
+				}
+			} else {
+				if width < 8 {
+					shift := 8 - width
+					v = int32(int8(value<<shift) >> shift)
+				} else {
+					v = int32(int8(value))
+				}
+			}
+
+			if r.is16 {
+				d1 = int32(int16(d1 + v))
+				d2 = int32(int16(d2 + d1))
+			} else {
+				d1 = int32(int8(d1 + v))
+				d2 = int32(int8(d2 + d1))
+			}
+			if r.it215 {
+				out = append(out, d2)
+			} else {
+				out = append(out, d1)
+			}
+			blkpos++
+		}
+	}
+	return out, nil
+}
+
+func nextIT214Width(value, width uint8) uint8 {
+	if value < width {
+		return value
+	}
+	return value + 1
+}
+
+// readBits reads `n` bits from the current block, least significant bit first
+func (r *it214Reader) readBits(n int8) (uint32, bool) {
+	var value uint32
+	for i := n; i > 0; i-- {
+		if r.bitnum == 0 {
+			if len(r.block) == 0 {
+				return 0, false
+			}
+			r.bitbuf = uint32(r.block[0])
+			r.block = r.block[1:]
+			r.bitnum = 8
+		}
+		value >>= 1
+		value |= r.bitbuf << 31
+		r.bitbuf >>= 1
+		r.bitnum--
+	}
+	return value >> (32 - uint32(n)), true
+}
@@ -0,0 +1,101 @@
+package extract
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// compressIT214 packs deltas at the initial bit width of the format, which is the simplest
+// valid IT 2.14 stream, splitting it into blocks of `blockLen` values
+func compressIT214(deltas []int32, is16 bool) []byte {
+	width, blockLen := 9, 0x8000
+	if is16 {
+		width, blockLen = 17, 0x4000
+	}
+
+	var out []byte
+	for len(deltas) > 0 {
+		n := min(blockLen, len(deltas))
+		var block []byte
+		var bitbuf uint64
+		var bitnum int
+		for _, d := range deltas[:n] {
+			// the top bit is clear, so every value is a plain delta
+			v := uint64(d) & (1<<(width-1) - 1)
+			bitbuf |= v << bitnum
+			bitnum += width
+			for bitnum >= 8 {
+				block = append(block, byte(bitbuf))
+				bitbuf >>= 8
+				bitnum -= 8
+			}
+		}
+		if bitnum > 0 {
+			block = append(block, byte(bitbuf))
+		}
+		out = binary.LittleEndian.AppendUint16(out, uint16(len(block)))
+		out = append(out, block...)
+		deltas = deltas[n:]
+	}
+	return out
+}
+
+func TestIT214Decompress(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		is16  bool
+		it215 bool
+		count int
+	}{
+		{"8-bit", false, false, 0x8000 + 100},
+		{"8-bit IT215", false, true, 1000},
+		{"16-bit", true, false, 0x4000 + 100},
+		{"16-bit IT215", true, true, 1000},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			deltas := make([]int32, tc.count)
+			for i := range deltas {
+				deltas[i] = int32(i%7) - 3
+			}
+
+			r := it214Reader{
+				data:  compressIT214(deltas, tc.is16),
+				is16:  tc.is16,
+				it215: tc.it215,
+			}
+			values, err := r.decompress(tc.count)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(values) != tc.count {
+				t.Fatalf("expected %d values, got %d", tc.count, len(values))
+			}
+
+			// deltas restart at every block
+			blockLen := 0x8000
+			if tc.is16 {
+				blockLen = 0x4000
+			}
+			var d1, d2 int32
+			for i, d := range deltas {
+				if i%blockLen == 0 {
+					d1, d2 = 0, 0
+				}
+				d1 += d
+				d2 += d1
+				expected := d1
+				if tc.it215 {
+					expected = d2
+				}
+				if tc.is16 {
+					expected = int32(int16(expected))
+				} else {
+					expected = int32(int8(expected))
+				}
+				if values[i] != expected {
+					t.Fatalf("value %d: expected %d, got %d", i, expected, values[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package extract
+
+import "encoding/binary"
+
+// pcmFormat describes how sample data is stored in a song file
+type pcmFormat struct {
+	channels int
+	is16Bit  bool
+	signed   bool
+	order    binary.ByteOrder
+}
+
+// decodePCM splits `data` into `length` frames of values per channel.
+// Trackers store the channels of a stereo sample one after the other, rather than interleaved.
+// Data missing from the end of a truncated file is treated as silence.
+func decodePCM(data []byte, length int, format pcmFormat) [][]int32 {
+	bytesPerValue := 1
+	if format.is16Bit {
+		bytesPerValue = 2
+	}
+	order := format.order
+	if order == nil {
+		order = binary.LittleEndian
+	}
+
+	out := make([][]int32, format.channels)
+	pos := 0
+	for c := range out {
+		values := make([]int32, length)
+		for i := range values {
+			if pos+bytesPerValue > len(data) {
+				break
+			}
+			if format.is16Bit {
+				v := order.Uint16(data[pos:])
+				if format.signed {
+					values[i] = int32(int16(v))
+				} else {
+					values[i] = int32(v) - 0x8000
+				}
+			} else {
+				v := data[pos]
+				if format.signed {
+					values[i] = int32(int8(v))
+				} else {
+					values[i] = int32(v) - 0x80
+				}
+			}
+			pos += bytesPerValue
+		}
+		out[c] = values
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+package extract
+
+import (
+	"bytes"
+
+	"github.com/gotracker/goaudiofile/music/tracked/s3m"
+	"github.com/gotracker/playback/format/s3m/load/modconv"
+
+	"github.com/gotracker/gotracker/internal/songinfo"
+)
+
+func readS3MSamples(data []byte) ([]Sample, error) {
+	f, err := s3m.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return s3mSamples(f), nil
+}
+
+// readMODSamples reads the samples of a MOD file by way of its S3M conversion,
+// which also works out the sample rate from the finetune
+func readMODSamples(data []byte) ([]Sample, error) {
+	f, err := modconv.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return s3mSamples(f), nil
+}
+
+func s3mSamples(f *s3m.File) []Sample {
+	signed := f.Head.FileFormatInformation == 1
+
+	var samples []Sample
+	for i, inst := range f.Instruments {
+		s := Sample{
+			Number: i + 1,
+		}
+		// OPL2 (adlib) instruments have no sample data to extract
+		if si, ok := inst.Ancillary.(*s3m.SCRSDigiplayerHeader); ok && si.PackingScheme == s3m.PackingUnpacked {
+			s.Name = songinfo.CleanName(si.GetSampleName())
+			s.SampleRate = int(si.C2Spd.Lo)
+			if s.SampleRate == 0 {
+				s.SampleRate = int(s3m.DefaultC2Spd)
+			}
+
+			format := pcmFormat{
+				channels: 1,
+				is16Bit:  si.Flags.Is16BitSample(),
+				signed:   signed,
+			}
+			if si.Flags.IsStereo() {
+				format.channels = 2
+			}
+			s.BitsPerSample = 8
+			if format.is16Bit {
+				s.BitsPerSample = 16
+			}
+			s.Data = decodePCM(inst.Sample, int(si.Length.Lo), format)
+
+			if si.Flags.IsLooped() {
+				s.Loops = append(s.Loops, Loop{
+					Begin: int(si.LoopBegin.Lo),
+					End:   int(si.LoopEnd.Lo),
+				})
+			}
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
@@ -0,0 +1,64 @@
+package extract
+
+import (
+	"bufio"
+	"encoding/binary"
+	"os"
+
+	"github.com/gotracker/gotracker/internal/output/device/file"
+)
+
+func writeWavSample(filename string, s *Sample) error {
+	format := file.WavFormat{
+		Channels:         len(s.Data),
+		SamplesPerSecond: s.SampleRate,
+		BitsPerSample:    s.BitsPerSample,
+	}
+
+	// wave files hold interleaved frames, with 8-bit values being unsigned
+	bytesPerValue := s.BitsPerSample / 8
+	data := make([]byte, 0, s.Len()*len(s.Data)*bytesPerValue)
+	for i := 0; i < s.Len(); i++ {
+		for _, values := range s.Data {
+			if s.BitsPerSample == 8 {
+				data = append(data, uint8(values[i]+0x80))
+			} else {
+				data = binary.LittleEndian.AppendUint16(data, uint16(values[i]))
+			}
+		}
+	}
+
+	var chunks []file.WavChunk
+	if s.Name != "" {
+		chunks = append(chunks, file.NewWavInfoChunk(file.WavInfoTag{
+			ID:    [4]byte{'I', 'N', 'A', 'M'},
+			Value: s.Name,
+		}))
+	}
+	if len(s.Loops) > 0 {
+		var loops []file.WavLoop
+		for _, l := range s.Loops {
+			loops = append(loops, file.WavLoop{
+				Begin:    l.Begin,
+				End:      l.End,
+				PingPong: l.PingPong,
+			})
+		}
+		chunks = append(chunks, file.NewWavSamplerChunk(s.SampleRate, loops...))
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if err := file.WriteWav(w, format, data, chunks...); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
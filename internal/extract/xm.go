@@ -0,0 +1,61 @@
+package extract
+
+import (
+	"bytes"
+
+	"github.com/gotracker/goaudiofile/music/tracked/xm"
+	xmPeriod "github.com/gotracker/playback/format/xm/period"
+	xmSystem "github.com/gotracker/playback/format/xm/system"
+	"github.com/gotracker/playback/note"
+
+	"github.com/gotracker/gotracker/internal/songinfo"
+)
+
+func readXMSamples(data []byte) ([]Sample, error) {
+	f, err := xm.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []Sample
+	for _, inst := range f.Instruments {
+		for _, si := range inst.Samples {
+			s := Sample{
+				Number:        len(samples) + 1,
+				Name:          songinfo.CleanName(si.GetName()),
+				BitsPerSample: 8,
+			}
+
+			n := note.Semitone(xmSystem.C4Note + si.RelativeNoteNumber)
+			s.SampleRate = int(xmPeriod.CalcFinetuneC4SampleRate(xmSystem.DefaultC4SampleRate, n, note.Finetune(si.Finetune)))
+
+			// lengths and loop points are stored in bytes
+			format := pcmFormat{
+				channels: 1,
+				is16Bit:  si.Flags.Is16Bit(),
+				signed:   true,
+			}
+			stride := 1
+			if si.Flags.IsStereo() {
+				format.channels = 2
+				stride *= 2
+			}
+			if format.is16Bit {
+				s.BitsPerSample = 16
+				stride *= 2
+			}
+			s.Data = decodePCM(si.SampleData, int(si.Length)/stride, format)
+
+			switch si.Flags.LoopMode() {
+			case xm.SampleLoopModeEnabled, xm.SampleLoopModePingPong:
+				s.Loops = append(s.Loops, Loop{
+					Begin:    int(si.LoopStart) / stride,
+					End:      int(si.LoopStart+si.LoopLength) / stride,
+					PingPong: si.Flags.LoopMode() == xm.SampleLoopModePingPong,
+				})
+			}
+			samples = append(samples, s)
+		}
+	}
+	return samples, nil
+}
@@ -0,0 +1,61 @@
+package keyboard
+
+import (
+	"bufio"
+	"errors"
+	"os"
+)
+
+var (
+	// ErrNotSupported is returned when the keyboard cannot be read on the current system
+	ErrNotSupported = errors.New("keyboard input not supported")
+)
+
+// Keyboard reads individual keypresses from a terminal
+type Keyboard struct {
+	keys    chan rune
+	restore func() error
+}
+
+// Open puts the terminal attached to `f` into an unbuffered, non-echoing mode
+// and starts reading keypresses from it
+func Open(f *os.File) (*Keyboard, error) {
+	restore, err := makeRaw(f)
+	if err != nil {
+		return nil, err
+	}
+
+	k := Keyboard{
+		keys:    make(chan rune, 16),
+		restore: restore,
+	}
+
+	go func() {
+		defer close(k.keys)
+		r := bufio.NewReader(f)
+		for {
+			c, _, err := r.ReadRune()
+			if err != nil {
+				return
+			}
+			k.keys <- c
+		}
+	}()
+
+	return &k, nil
+}
+
+// Keys returns the channel of keypresses
+func (k *Keyboard) Keys() <-chan rune {
+	return k.keys
+}
+
+// Close returns the terminal to the mode it was in prior to Open
+func (k *Keyboard) Close() error {
+	if k.restore == nil {
+		return nil
+	}
+	restore := k.restore
+	k.restore = nil
+	return restore()
+}
@@ -0,0 +1,30 @@
+//go:build linux
+// +build linux
+
+package keyboard
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func makeRaw(f *os.File) (func() error, error) {
+	fd := int(f.Fd())
+	orig, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, ErrNotSupported
+	}
+
+	raw := *orig
+	raw.Lflag &^= unix.ICANON | unix.ECHO
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return unix.IoctlSetTermios(fd, unix.TCSETS, orig)
+	}, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package keyboard
+
+import (
+	"os"
+)
+
+func makeRaw(f *os.File) (func() error, error) {
+	return nil, ErrNotSupported
+}
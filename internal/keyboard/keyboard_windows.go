@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package keyboard
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+func makeRaw(f *os.File) (func() error, error) {
+	h := windows.Handle(f.Fd())
+	var orig uint32
+	if err := windows.GetConsoleMode(h, &orig); err != nil {
+		return nil, ErrNotSupported
+	}
+
+	raw := orig &^ (windows.ENABLE_LINE_INPUT | windows.ENABLE_ECHO_INPUT)
+	if err := windows.SetConsoleMode(h, raw); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return windows.SetConsoleMode(h, orig)
+	}, nil
+}
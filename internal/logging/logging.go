@@ -1,7 +1,24 @@
 package logging
 
+import (
+	"io"
+	"os"
+)
+
 type Log interface {
 	Print(args ...any)
 	Printf(format string, args ...any)
 	Println(args ...any)
 }
+
+type writerGetter interface {
+	Writer() io.Writer
+}
+
+// Writer returns where the passed in log writes to
+func Writer(l Log) io.Writer {
+	if w, ok := l.(writerGetter); ok {
+		return w.Writer()
+	}
+	return os.Stdout
+}
@@ -2,29 +2,41 @@ package logging
 
 import (
 	"fmt"
+	"io"
+	"os"
 )
 
 type Squelchable struct {
 	Squelch bool `pflag:"silent" env:"silent" pf:"q" usage:"disable non-error logging"`
+	// Output is where the logging goes (nil = standard output)
+	Output io.Writer
+}
+
+// Writer returns where the logging goes
+func (s *Squelchable) Writer() io.Writer {
+	if s.Output == nil {
+		return os.Stdout
+	}
+	return s.Output
 }
 
 func (s *Squelchable) Printf(format string, args ...any) {
 	if s.Squelch {
 		return
 	}
-	fmt.Printf(format, args...)
+	fmt.Fprintf(s.Writer(), format, args...)
 }
 
 func (s *Squelchable) Println(args ...any) {
 	if s.Squelch {
 		return
 	}
-	fmt.Println(args...)
+	fmt.Fprintln(s.Writer(), args...)
 }
 
 func (s *Squelchable) Print(args ...any) {
 	if s.Squelch {
 		return
 	}
-	fmt.Print(args...)
+	fmt.Fprint(s.Writer(), args...)
 }
@@ -0,0 +1,179 @@
+// Package loudness measures the loudness of audio, as per ITU-R BS.1770
+// (which is what EBU R 128 and ReplayGain 2.0 are built on)
+package loudness
+
+import "math"
+
+const (
+	// the measurement is built up from blocks of 400ms, overlapping each other by 75%
+	subblocksPerBlock = 4
+	subblockDuration  = 0.1 // seconds
+
+	absoluteGate = -70.0 // LUFS
+	relativeGate = -10.0 // LU
+)
+
+// biquad is a second-order IIR filter
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+	x1, x2     float64
+	y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x1, f.x2 = x, f.x1
+	f.y1, f.y2 = y, f.y1
+	return y
+}
+
+// newKWeighting returns the two filter stages of the K-weighting curve at `sampleRate`:
+// a high shelf modelling the head, followed by a high pass
+func newKWeighting(sampleRate int) [2]biquad {
+	fs := float64(sampleRate)
+
+	// stage 1: high shelf
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// stage 2: high pass
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return [2]biquad{shelf, highPass}
+}
+
+// Meter measures the integrated loudness and the sample peak of audio
+type Meter struct {
+	filters [][2]biquad
+	weights []float64
+
+	subblockLen int
+	// the (weighted) sum of squares of the current subblock, and how many samples are in it
+	subblockSum float64
+	subblockPos int
+	// the sums of the most recent subblocks
+	recent []float64
+	// the mean square of every block
+	blocks []float64
+
+	peak float64
+}
+
+// NewMeter returns a meter for audio of `channels` channels at `sampleRate`
+func NewMeter(channels, sampleRate int) *Meter {
+	m := Meter{
+		filters:     make([][2]biquad, channels),
+		weights:     make([]float64, channels),
+		subblockLen: int(float64(sampleRate) * subblockDuration),
+	}
+	for c := range m.filters {
+		m.filters[c] = newKWeighting(sampleRate)
+		m.weights[c] = 1.0
+		if channels == 4 && c >= 2 {
+			// the rear channels of quadraphonic audio are surround channels, which count for more
+			m.weights[c] = 1.41
+		}
+	}
+	return &m
+}
+
+// Add measures `data`, which is a slice of samples for each channel, of `bitsPerSample` bits
+func (m *Meter) Add(data [][]int32, bitsPerSample int) {
+	if len(data) == 0 || len(data) != len(m.filters) {
+		return
+	}
+
+	scale := 1 / float64(int64(1)<<(bitsPerSample-1))
+	for i := range data[0] {
+		var sum float64
+		for c, samples := range data {
+			x := float64(samples[i]) * scale
+			m.peak = max(m.peak, math.Abs(x))
+			y := m.filters[c][0].process(x)
+			y = m.filters[c][1].process(y)
+			sum += m.weights[c] * y * y
+		}
+
+		m.subblockSum += sum
+		m.subblockPos++
+		if m.subblockPos >= m.subblockLen {
+			m.endSubblock()
+		}
+	}
+}
+
+func (m *Meter) endSubblock() {
+	m.recent = append(m.recent, m.subblockSum)
+	m.subblockSum = 0
+	m.subblockPos = 0
+	if len(m.recent) < subblocksPerBlock {
+		return
+	}
+	m.recent = m.recent[len(m.recent)-subblocksPerBlock:]
+
+	var sum float64
+	for _, s := range m.recent {
+		sum += s
+	}
+	m.blocks = append(m.blocks, sum/float64(subblocksPerBlock*m.subblockLen))
+}
+
+func loudness(meanSquare float64) float64 {
+	return -0.691 + 10*math.Log10(meanSquare)
+}
+
+// Integrated returns the gated loudness of everything measured so far (in LUFS),
+// or false if there's not enough (non-silent) audio to tell
+func (m *Meter) Integrated() (float64, bool) {
+	var (
+		sum float64
+		n   int
+	)
+	for _, b := range m.blocks {
+		if b > 0 && loudness(b) > absoluteGate {
+			sum += b
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+
+	gate := loudness(sum/float64(n)) + relativeGate
+	sum, n = 0, 0
+	for _, b := range m.blocks {
+		if b > 0 && loudness(b) > absoluteGate && loudness(b) > gate {
+			sum += b
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return loudness(sum / float64(n)), true
+}
+
+// Peak returns the highest absolute sample value measured so far, where 1.0 is full scale
+func (m *Meter) Peak() float64 {
+	return m.peak
+}
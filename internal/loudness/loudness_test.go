@@ -0,0 +1,46 @@
+package loudness
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeterSine(t *testing.T) {
+	// a 1kHz sine at -20dBFS in both channels of stereo audio measures -20 LUFS
+	const (
+		sampleRate = 48000
+		bits       = 24
+	)
+	amplitude := 0.1 * float64(int(1)<<(bits-1))
+	data := [][]int32{
+		make([]int32, sampleRate*5),
+		make([]int32, sampleRate*5),
+	}
+	for i := range data[0] {
+		v := int32(amplitude * math.Sin(2*math.Pi*1000*float64(i)/sampleRate))
+		data[0][i] = v
+		data[1][i] = v
+	}
+
+	m := NewMeter(2, sampleRate)
+	m.Add(data, bits)
+
+	lufs, ok := m.Integrated()
+	if !ok {
+		t.Fatal("expected a measurement")
+	}
+	if math.Abs(lufs-(-20)) > 0.1 {
+		t.Errorf("expected -20 LUFS, got %.2f", lufs)
+	}
+	if math.Abs(m.Peak()-0.1) > 0.001 {
+		t.Errorf("expected a peak of 0.1, got %.4f", m.Peak())
+	}
+}
+
+func TestMeterSilence(t *testing.T) {
+	m := NewMeter(1, 44100)
+	m.Add([][]int32{make([]int32, 44100)}, 16)
+	if _, ok := m.Integrated(); ok {
+		t.Error("expected no measurement of silence")
+	}
+}
@@ -0,0 +1,135 @@
+package mastering
+
+import (
+	"math"
+	"time"
+
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/mixing/volume"
+)
+
+// limiter is a look-ahead peak limiter. The audio is held back for the length of the look-ahead,
+// so that the gain can already be ramped down by the time a peak comes out.
+//
+// The gain needed to keep each sample under the ceiling is held at its lowest for the length of
+// the look-ahead, released back up gradually, then averaged over the length of the look-ahead.
+// Every gain averaged into the one a sample comes out with was held from before the sample went in,
+// so no sample ever comes out over the ceiling.
+type limiter struct {
+	sampleRate  int
+	ceiling     float64
+	releaseCoef float64
+	length      int
+
+	// the audio held back
+	delay  mixing.MixBuffer
+	pos    int
+	filled int
+
+	// the lowest gains needed over the look-ahead, as (sample number, gain) pairs of increasing gain
+	holds []limiterHold
+	n     int
+
+	gain float64
+
+	// the released gains being averaged
+	avg    []float64
+	avgPos int
+	avgSum float64
+}
+
+type limiterHold struct {
+	n    int
+	gain float64
+}
+
+func newLimiter(sampleRate int, ceiling float64, release time.Duration) *limiter {
+	length := max(int(limiterLookAhead.Seconds()*float64(sampleRate)), 1)
+	l := limiter{
+		sampleRate: sampleRate,
+		length:     length,
+		delay:      make(mixing.MixBuffer, length),
+		holds:      make([]limiterHold, 0, length+1),
+		gain:       1,
+		avg:        make([]float64, length),
+		avgSum:     float64(length),
+	}
+	for i := range l.avg {
+		l.avg[i] = 1
+	}
+	l.set(ceiling, release)
+	return &l
+}
+
+// set changes the ceiling (in dBFS) and the release time of the limiter
+func (l *limiter) set(ceiling float64, release time.Duration) {
+	l.ceiling = math.Pow(10, ceiling/20)
+	l.releaseCoef = 0
+	if samples := release.Seconds() * float64(l.sampleRate); samples > 0 {
+		l.releaseCoef = math.Exp(-1 / samples)
+	}
+}
+
+// process takes in a sample and returns the one coming out, if the look-ahead has filled up
+func (l *limiter) process(in volume.Matrix) (volume.Matrix, bool) {
+	var peak float64
+	for c := 0; c < in.Channels; c++ {
+		peak = max(peak, math.Abs(float64(in.StaticMatrix[c])))
+	}
+	needed := 1.0
+	if peak > l.ceiling {
+		needed = l.ceiling / peak
+	}
+
+	// hold the lowest gain needed over the look-ahead, counting the sample that's about to come out
+	for len(l.holds) > 0 && l.holds[len(l.holds)-1].gain >= needed {
+		l.holds = l.holds[:len(l.holds)-1]
+	}
+	l.holds = append(l.holds, limiterHold{n: l.n, gain: needed})
+	if l.n-l.holds[0].n > l.length {
+		l.holds = l.holds[1:]
+	}
+	l.n++
+	held := l.holds[0].gain
+
+	// drop straight down to the held gain, but only ease back up from it
+	if held < l.gain {
+		l.gain = held
+	} else {
+		l.gain = held + (l.gain-held)*l.releaseCoef
+	}
+
+	l.avgSum += l.gain - l.avg[l.avgPos]
+	l.avg[l.avgPos] = l.gain
+	l.avgPos = (l.avgPos + 1) % l.length
+	gain := volume.Volume(min(l.avgSum/float64(l.length), 1))
+
+	out := l.delay[l.pos]
+	l.delay[l.pos] = in
+	l.pos = (l.pos + 1) % l.length
+	if l.filled < l.length {
+		l.filled++
+		return out, false
+	}
+	return out.Apply(gain), true
+}
+
+// flush returns the audio held back, emptying out the look-ahead
+func (l *limiter) flush() mixing.MixBuffer {
+	var silence volume.Matrix
+	for i := l.pos; i < l.pos+l.length; i++ {
+		if frame := l.delay[i%l.length]; frame.Channels != 0 {
+			silence.Channels = frame.Channels
+		}
+	}
+
+	// push silence through until everything held back has come out
+	data := make(mixing.MixBuffer, 0, l.filled)
+	for i := 0; i < l.length; i++ {
+		if out, ok := l.process(silence); ok {
+			data = append(data, out)
+		}
+	}
+	l.filled = 0
+	return data
+}
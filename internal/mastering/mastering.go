@@ -0,0 +1,162 @@
+// Package mastering runs the final mix through a mastering chain - a DC-offset high pass,
+// a look-ahead peak limiter and a soft clipper - so that loud songs come out without clipping
+package mastering
+
+import (
+	"math"
+	"time"
+
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/mixing/volume"
+)
+
+const (
+	// how far ahead the limiter looks for peaks (which is also how far it delays the audio)
+	limiterLookAhead = 5 * time.Millisecond
+	// the corner frequency of the DC-offset high pass
+	dcFilterCutoff = 5.0 // Hz
+	// the soft clipper leaves anything quieter than this alone
+	softClipKnee = 0.8
+
+	maxChannels = len(volume.StaticMatrix{})
+)
+
+// Settings selects which stages of the chain are run
+type Settings struct {
+	DCFilter bool
+	Limiter  bool
+	// the highest level the limiter lets through (in dBFS)
+	LimiterCeiling float64
+	// how long the limiter takes to let go after a peak
+	LimiterRelease time.Duration
+	SoftClip       bool
+}
+
+// IsEnabled returns true if any of the stages are to be run
+func (s Settings) IsEnabled() bool {
+	return s.DCFilter || s.Limiter || s.SoftClip
+}
+
+// Master is a mastering chain. Its state carries over from one buffer to the next,
+// so it expects to be handed the mix in order.
+type Master struct {
+	sampleRate int
+	settings   Settings
+
+	dc  *dcFilter
+	lim *limiter
+}
+
+// New returns a mastering chain for audio at `sampleRate` with all of its stages switched off
+func New(sampleRate int) *Master {
+	return &Master{
+		sampleRate: sampleRate,
+	}
+}
+
+// Configure changes the stages that are run, picking up where the audio left off.
+// It returns whatever audio was held back by the stages being switched off.
+func (m *Master) Configure(s Settings) mixing.MixBuffer {
+	var tail mixing.MixBuffer
+	switch {
+	case !s.Limiter:
+		if m.lim != nil {
+			tail = m.Flush()
+			m.lim = nil
+		}
+	case m.lim == nil:
+		m.lim = newLimiter(m.sampleRate, s.LimiterCeiling, s.LimiterRelease)
+	default:
+		// carry on with the audio already in the limiter, so that it isn't delayed any further
+		m.lim.set(s.LimiterCeiling, s.LimiterRelease)
+	}
+
+	switch {
+	case !s.DCFilter:
+		m.dc = nil
+	case m.dc == nil:
+		m.dc = newDCFilter(m.sampleRate)
+	}
+
+	m.settings = s
+	return tail
+}
+
+// IsEnabled returns true if any of the stages are being run
+func (m *Master) IsEnabled() bool {
+	return m.settings.IsEnabled()
+}
+
+// Process runs `data` through the chain, returning the processed audio.
+// While the limiter is running, the audio comes out delayed by its look-ahead, so
+// the amount of audio returned may differ from the amount passed in.
+func (m *Master) Process(data mixing.MixBuffer) mixing.MixBuffer {
+	out := make(mixing.MixBuffer, 0, len(data))
+	for _, frame := range data {
+		if m.dc != nil {
+			frame = m.dc.process(frame)
+		}
+		if m.lim != nil {
+			var ok bool
+			if frame, ok = m.lim.process(frame); !ok {
+				continue
+			}
+		}
+		out = append(out, m.finish(frame))
+	}
+	return out
+}
+
+// Flush returns whatever audio is still held back by the chain
+func (m *Master) Flush() mixing.MixBuffer {
+	if m.lim == nil {
+		return nil
+	}
+	data := m.lim.flush()
+	for i, frame := range data {
+		data[i] = m.finish(frame)
+	}
+	return data
+}
+
+// finish runs the stages that come after the limiter
+func (m *Master) finish(frame volume.Matrix) volume.Matrix {
+	if m.settings.SoftClip {
+		for c := 0; c < frame.Channels; c++ {
+			frame.StaticMatrix[c] = volume.Volume(softClip(float64(frame.StaticMatrix[c])))
+		}
+	}
+	return frame
+}
+
+// softClip rounds off anything louder than the knee, so that it approaches full scale without ever reaching it
+func softClip(x float64) float64 {
+	mag := math.Abs(x)
+	if mag <= softClipKnee {
+		return x
+	}
+	const room = 1 - softClipKnee
+	return math.Copysign(softClipKnee+room*math.Tanh((mag-softClipKnee)/room), x)
+}
+
+// dcFilter is a one-pole high pass that removes any DC offset from the audio
+type dcFilter struct {
+	r      float64
+	x1, y1 [maxChannels]float64
+}
+
+func newDCFilter(sampleRate int) *dcFilter {
+	return &dcFilter{
+		r: math.Exp(-2 * math.Pi * dcFilterCutoff / float64(sampleRate)),
+	}
+}
+
+func (f *dcFilter) process(frame volume.Matrix) volume.Matrix {
+	for c := 0; c < frame.Channels; c++ {
+		x := float64(frame.StaticMatrix[c])
+		y := x - f.x1[c] + f.r*f.y1[c]
+		f.x1[c], f.y1[c] = x, y
+		frame.StaticMatrix[c] = volume.Volume(y)
+	}
+	return frame
+}
@@ -0,0 +1,74 @@
+package mastering
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/mixing/volume"
+)
+
+func newTestTone(sampleRate, samples int, amplitude, offset float64) mixing.MixBuffer {
+	data := make(mixing.MixBuffer, samples)
+	for i := range data {
+		v := volume.Volume(offset + amplitude*math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)))
+		data[i].Channels = 2
+		data[i].StaticMatrix[0] = v
+		data[i].StaticMatrix[1] = -v
+	}
+	return data
+}
+
+func TestLimiterKeepsUnderCeiling(t *testing.T) {
+	const sampleRate = 44100
+	m := New(sampleRate)
+	m.Configure(Settings{
+		Limiter:        true,
+		LimiterCeiling: -1,
+		LimiterRelease: 50 * time.Millisecond,
+	})
+
+	in := newTestTone(sampleRate, sampleRate, 0.25, 0)
+	// a burst of something far too loud
+	copy(in[sampleRate/2:], newTestTone(sampleRate, sampleRate/10, 4, 0))
+
+	var out mixing.MixBuffer
+	for len(in) > 0 {
+		n := min(len(in), 882)
+		out = append(out, m.Process(in[:n])...)
+		in = in[n:]
+	}
+	out = append(out, m.Flush()...)
+
+	if len(out) != sampleRate {
+		t.Fatalf("expected %d samples to come out, got %d", sampleRate, len(out))
+	}
+	ceiling := math.Pow(10, -1.0/20)
+	for i, frame := range out {
+		for c := 0; c < frame.Channels; c++ {
+			if v := math.Abs(float64(frame.StaticMatrix[c])); v > ceiling+1e-6 {
+				t.Fatalf("sample %d channel %d is over the ceiling: %f", i, c, v)
+			}
+		}
+	}
+}
+
+func TestDCFilterRemovesOffset(t *testing.T) {
+	const sampleRate = 44100
+	m := New(sampleRate)
+	m.Configure(Settings{
+		DCFilter: true,
+	})
+
+	out := m.Process(newTestTone(sampleRate, 2*sampleRate, 0.25, 0.5))
+
+	var sum float64
+	tail := out[len(out)-sampleRate/10:]
+	for _, frame := range tail {
+		sum += float64(frame.StaticMatrix[0])
+	}
+	if mean := sum / float64(len(tail)); math.Abs(mean) > 0.01 {
+		t.Fatalf("expected the offset to be removed, but the mean is %f", mean)
+	}
+}
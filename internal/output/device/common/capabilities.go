@@ -0,0 +1,148 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// MixerChannels are the channel counts the mixer can pan to, which is as many as any device can be given
+	MixerChannels = []int{1, 2, 4}
+)
+
+// Capabilities describes the formats an output device can play
+type Capabilities struct {
+	// MinSampleRate and MaxSampleRate are the range of sample rates supported (a MaxSampleRate of 0 means there is no limit)
+	MinSampleRate int
+	MaxSampleRate int
+	// BitsPerSample are the supported sizes of a sample, in ascending order (none means any size will do)
+	BitsPerSample []int
+	// Channels are the supported channel counts, in ascending order
+	Channels []int
+	// SampleFormats is set when the device takes the sample format setting over the bits per sample, when it's set
+	SampleFormats bool
+}
+
+// Intersect returns the formats supported by both `c` and `o`
+func (c Capabilities) Intersect(o Capabilities) Capabilities {
+	r := Capabilities{
+		MinSampleRate: max(c.MinSampleRate, o.MinSampleRate),
+		MaxSampleRate: c.MaxSampleRate,
+		BitsPerSample: intersectInts(c.BitsPerSample, o.BitsPerSample),
+		Channels:      intersectInts(c.Channels, o.Channels),
+		SampleFormats: c.SampleFormats && o.SampleFormats,
+	}
+	if r.MaxSampleRate == 0 || (o.MaxSampleRate != 0 && o.MaxSampleRate < r.MaxSampleRate) {
+		r.MaxSampleRate = o.MaxSampleRate
+	}
+	return r
+}
+
+// Union returns the formats supported by either `c` or `o`
+func (c Capabilities) Union(o Capabilities) Capabilities {
+	r := Capabilities{
+		MinSampleRate: min(c.MinSampleRate, o.MinSampleRate),
+		MaxSampleRate: max(c.MaxSampleRate, o.MaxSampleRate),
+		Channels:      unionInts(c.Channels, o.Channels),
+		SampleFormats: c.SampleFormats || o.SampleFormats,
+	}
+	if c.MaxSampleRate == 0 || o.MaxSampleRate == 0 {
+		r.MaxSampleRate = 0
+	}
+	if len(c.BitsPerSample) != 0 && len(o.BitsPerSample) != 0 {
+		r.BitsPerSample = unionInts(c.BitsPerSample, o.BitsPerSample)
+	}
+	return r
+}
+
+// NearestSampleRate returns the supported sample rate nearest to `rate`
+func (c Capabilities) NearestSampleRate(rate int) int {
+	rate = max(rate, c.MinSampleRate, 1)
+	if c.MaxSampleRate != 0 {
+		rate = min(rate, c.MaxSampleRate)
+	}
+	return rate
+}
+
+// NearestBitsPerSample returns the supported sample size nearest to `bits`, favoring the larger one of a tie
+func (c Capabilities) NearestBitsPerSample(bits int) int {
+	if len(c.BitsPerSample) == 0 {
+		return bits
+	}
+	return nearestInt(c.BitsPerSample, bits)
+}
+
+// NearestChannels returns the supported channel count nearest to `channels`, favoring the larger one of a tie
+func (c Capabilities) NearestChannels(channels int) int {
+	return nearestInt(c.Channels, channels)
+}
+
+// SampleRatesString describes the supported sample rates, e.g.: `100-200000`
+func (c Capabilities) SampleRatesString() string {
+	if c.MaxSampleRate == 0 {
+		return fmt.Sprintf("%d+", c.MinSampleRate)
+	}
+	return fmt.Sprintf("%d-%d", c.MinSampleRate, c.MaxSampleRate)
+}
+
+// BitsPerSampleString describes the supported sample sizes, e.g.: `8/16`
+func (c Capabilities) BitsPerSampleString() string {
+	if len(c.BitsPerSample) == 0 {
+		return "any"
+	}
+	return joinInts(c.BitsPerSample)
+}
+
+// ChannelsString describes the supported channel counts, e.g.: `1/2/4`
+func (c Capabilities) ChannelsString() string {
+	return joinInts(c.Channels)
+}
+
+func intersectInts(a, b []int) []int {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	r := []int{}
+	for _, v := range a {
+		if slices.Contains(b, v) {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+func unionInts(a, b []int) []int {
+	r := slices.Concat(a, b)
+	slices.Sort(r)
+	return slices.Compact(r)
+}
+
+func nearestInt(list []int, v int) int {
+	best := list[0]
+	for _, n := range list[1:] {
+		if d, bd := abs(n-v), abs(best-v); d < bd || (d == bd && n > best) {
+			best = n
+		}
+	}
+	return best
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func joinInts(list []int) string {
+	s := make([]string, len(list))
+	for i, v := range list {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, "/")
+}
@@ -0,0 +1,46 @@
+package common
+
+import "testing"
+
+func TestCapabilitiesNearest(t *testing.T) {
+	caps := Capabilities{
+		MinSampleRate: 100,
+		MaxSampleRate: 200000,
+		BitsPerSample: []int{8, 16},
+		Channels:      MixerChannels,
+	}
+
+	for _, tc := range []struct {
+		name      string
+		got, want int
+	}{
+		{"rate in range", caps.NearestSampleRate(44100), 44100},
+		{"rate too high", caps.NearestSampleRate(384000), 200000},
+		{"rate too low", caps.NearestSampleRate(0), 100},
+		{"bits supported", caps.NearestBitsPerSample(8), 8},
+		{"bits too many", caps.NearestBitsPerSample(24), 16},
+		{"bits tied", caps.NearestBitsPerSample(12), 16},
+		{"channels tied", caps.NearestChannels(3), 4},
+		{"channels too many", caps.NearestChannels(6), 4},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestCapabilitiesIntersect(t *testing.T) {
+	mixer := Capabilities{MinSampleRate: 1, Channels: MixerChannels}
+	card := Capabilities{MinSampleRate: 100, MaxSampleRate: 200000, BitsPerSample: []int{8, 16}, Channels: []int{1, 2}}
+
+	c := mixer.Intersect(card)
+	if c.MinSampleRate != 100 || c.MaxSampleRate != 200000 {
+		t.Errorf("sample rates: got %s, want 100-200000", c.SampleRatesString())
+	}
+	if got := c.BitsPerSampleString(); got != "8/16" {
+		t.Errorf("bits per sample: got %s, want 8/16", got)
+	}
+	if got := c.ChannelsString(); got != "1/2" {
+		t.Errorf("channels: got %s, want 1/2", got)
+	}
+}
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// Dither names the way the mix gets dithered when it is reduced to integer samples
+type Dither string
+
+const (
+	// DitherNone truncates the mix, as the mixer does on its own
+	DitherNone = Dither("none")
+	// DitherTPDF adds triangular noise of up to 1 LSB either way, which
+	// turns the distortion of quiet passages into a steady hiss
+	DitherTPDF = Dither("tpdf")
+	// DitherShaped is TPDF dither with first-order noise shaping, which pushes the hiss up in frequency
+	DitherShaped = Dither("shaped")
+	// DitherLipshitz is TPDF dither with Lipshitz's 5-tap E-weighted noise shaping, which pushes the
+	// hiss into where it is heard the least at 44.1kHz
+	DitherLipshitz = Dither("lipshitz")
+)
+
+var (
+	// the noise shaping filters are applied to the errors of the most recent samples (newest first)
+	ditherShapes = map[Dither][]float64{
+		DitherTPDF:     nil,
+		DitherShaped:   {1},
+		DitherLipshitz: {2.033, -2.165, 1.959, -1.590, 0.6149},
+	}
+)
+
+const (
+	// the error fed back by the noise shaping is held to this many LSBs, so that
+	// a clipped sample doesn't send the filter off into oscillation
+	ditherMaxError = 2
+)
+
+// ditherer quantizes samples with dither, carrying the noise shaping over from one row to the next
+type ditherer struct {
+	shape []float64
+	errs  [][]float64
+	rng   *rand.Rand
+}
+
+func newDitherer(settings Settings) (*ditherer, error) {
+	dither := Dither(settings.Dither)
+	if dither == "" || dither == DitherNone {
+		return nil, nil
+	}
+	shape, ok := ditherShapes[dither]
+	if !ok {
+		return nil, fmt.Errorf("unsupported dither %q", settings.Dither)
+	}
+
+	d := ditherer{
+		shape: shape,
+		errs:  make([][]float64, settings.Channels),
+		// the noise is the same every time, so that renders are repeatable
+		rng: rand.New(rand.NewPCG(1, 2)),
+	}
+	for c := range d.errs {
+		d.errs[c] = make([]float64, len(shape))
+	}
+	return &d, nil
+}
+
+// quantize returns the samples of each channel (where 1.0 is full scale) as `bitsPerSample`-bit integers
+func (d *ditherer) quantize(samples [][]float32, bitsPerSample int) [][]int32 {
+	scale := float64(int64(1) << (bitsPerSample - 1))
+	lo, hi := -scale, scale-1
+
+	out := make([][]int32, len(samples))
+	for c, in := range samples {
+		out[c] = make([]int32, len(in))
+		errs := d.errs[c]
+		for i, v := range in {
+			x := float64(v) * scale
+			for k, h := range d.shape {
+				x -= h * errs[k]
+			}
+			q := math.Round(x + d.rng.Float64() - d.rng.Float64())
+			q = min(max(q, lo), hi)
+			if len(errs) > 0 {
+				copy(errs[1:], errs)
+				errs[0] = min(max(q-x, -ditherMaxError), ditherMaxError)
+			}
+			out[c][i] = int32(q)
+		}
+	}
+	return out
+}
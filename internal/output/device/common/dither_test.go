@@ -0,0 +1,50 @@
+package common
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDitherKeepsQuietSignals(t *testing.T) {
+	// a signal of a third of an LSB disappears when truncated, but lives on in the average of dithered samples
+	const (
+		bits    = 16
+		samples = 100000
+		level   = 0.3
+	)
+	in := make([]float32, samples)
+	for i := range in {
+		in[i] = level / (1 << (bits - 1))
+	}
+
+	for _, dither := range []Dither{DitherTPDF, DitherShaped, DitherLipshitz} {
+		t.Run(string(dither), func(t *testing.T) {
+			d, err := newDitherer(Settings{Channels: 1, Dither: string(dither)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out := d.quantize([][]float32{in}, bits)
+			var sum float64
+			for _, v := range out[0] {
+				if v < -16 || v > 16 {
+					t.Fatalf("dithered sample strayed too far: %d", v)
+				}
+				sum += float64(v)
+			}
+			if mean := sum / samples; math.Abs(mean-level) > 0.05 {
+				t.Errorf("expected a mean of %v, got %v", level, mean)
+			}
+		})
+	}
+}
+
+func TestDitherNone(t *testing.T) {
+	d, err := newDitherer(Settings{Channels: 2, Dither: string(DitherNone)})
+	if err != nil || d != nil {
+		t.Errorf("expected no ditherer, got %v (%v)", d, err)
+	}
+	if _, err := newDitherer(Settings{Channels: 2, Dither: "bogus"}); err == nil {
+		t.Error("expected an unsupported dither to fail")
+	}
+}
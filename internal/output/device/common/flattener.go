@@ -0,0 +1,90 @@
+package common
+
+import (
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/mixing/sampling"
+	"github.com/gotracker/playback/output"
+)
+
+// Flattener mixes premix data down to the samples an output device writes, carrying over
+// whatever has to last from one row to the next (such as the dither's noise shaping)
+type Flattener struct {
+	mix    mixing.Mixer
+	dither *ditherer
+}
+
+// NewFlattener returns a flattener for the output device described by `settings`
+func NewFlattener(settings Settings) (*Flattener, error) {
+	dither, err := newDitherer(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Flattener{
+		mix: mixing.Mixer{
+			Channels: settings.Channels,
+		},
+		dither: dither,
+	}, nil
+}
+
+// Channels returns the number of output channels
+func (fl *Flattener) Channels() int {
+	return fl.mix.Channels
+}
+
+// FlattenToFloats mixes the premix data down to separate channels of samples, where 1.0 is full scale
+func (fl *Flattener) FlattenToFloats(premix *output.PremixData) [][]float32 {
+	data := fl.mix.NewMixBuffer(premix.SamplesLen)
+	for _, rdata := range premix.Data {
+		for _, cdata := range rdata {
+			if cdata.Flush != nil {
+				cdata.Flush()
+			}
+			if len(cdata.Data) > 0 {
+				volMtx := cdata.PanMatrix.Apply(cdata.Volume)
+				data.Add(cdata.Pos, cdata.Data, volMtx)
+			}
+		}
+	}
+
+	out := make([][]float32, fl.mix.Channels)
+	for c := range out {
+		out[c] = make([]float32, premix.SamplesLen)
+	}
+	for i, samp := range data {
+		d := samp.Apply(premix.MixerVolume).ToChannels(fl.mix.Channels)
+		for c := range out {
+			out[c][i] = float32(d.StaticMatrix[c].WithOverflowProtection())
+		}
+	}
+	return out
+}
+
+// FlattenToInts mixes the premix data down to separate channels of `bitsPerSample`-bit integer samples
+func (fl *Flattener) FlattenToInts(premix *output.PremixData, bitsPerSample int) [][]int32 {
+	if fl.dither == nil {
+		return fl.mix.FlattenToInts(fl.mix.Channels, premix.SamplesLen, bitsPerSample, premix.Data, premix.MixerVolume)
+	}
+	return fl.dither.quantize(fl.FlattenToFloats(premix), bitsPerSample)
+}
+
+// Flatten mixes the premix data down to interleaved samples of the sample format `f`
+func (fl *Flattener) Flatten(premix *output.PremixData, f SampleFormat) []byte {
+	if fl.dither == nil {
+		switch f {
+		case SampleFormatS8:
+			return fl.mix.Flatten(premix.SamplesLen, premix.Data, premix.MixerVolume, sampling.Format8BitSigned)
+		case SampleFormatU8:
+			return fl.mix.Flatten(premix.SamplesLen, premix.Data, premix.MixerVolume, sampling.Format8BitUnsigned)
+		case SampleFormatS16LE:
+			return fl.mix.Flatten(premix.SamplesLen, premix.Data, premix.MixerVolume, sampling.Format16BitLESigned)
+		}
+	}
+	if f == SampleFormatF32LE {
+		// floats don't lose anything worth dithering
+		return fl.mix.Flatten(premix.SamplesLen, premix.Data, premix.MixerVolume, sampling.Format32BitLEFloat)
+	}
+
+	return f.pack(fl.FlattenToInts(premix, f.quantizedBits()), premix.SamplesLen)
+}
@@ -10,4 +10,6 @@ const (
 	KindFile
 	// KindSoundCard is an active sound playback device (e.g.: a sound card attached to speakers)
 	KindSoundCard
+	// KindStream is a live stream device type (e.g.: a server streaming to listeners as it plays)
+	KindStream
 )
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/output"
+)
+
+// TrackerOutput is the Userdata of a premix holding a mix that was made from another one (such as by running it
+// through an effect), carrying along the output of the tracker channels of the song from before it was, so that
+// they can still be output on their own (e.g.: as stems).
+type TrackerOutput struct {
+	// Channels holds the output of each of the tracker channels, in order
+	Channels mixing.ChannelData
+	// SamplesLen is the number of samples of the song the channels cover, which can be different to the
+	// number in the mix when some of it was held back (such as by the look-ahead of a limiter)
+	SamplesLen int
+	// Userdata is the Userdata of the premix the mix was made from
+	Userdata any
+}
+
+// TrackerChannels returns the output of each of the tracker channels of the song in `premix`,
+// along with the number of samples of the song they cover.
+// The first channel data of a premix is kept for the tracker channels, in order, with everything
+// else (such as background voices, or audio from a transition between songs) coming after it.
+func TrackerChannels(premix *output.PremixData) (mixing.ChannelData, int) {
+	if premix == nil {
+		return nil, 0
+	}
+	if t, ok := premix.Userdata.(*TrackerOutput); ok {
+		return t.Channels, t.SamplesLen
+	}
+	if len(premix.Data) == 0 {
+		return nil, 0
+	}
+	return premix.Data[0], premix.SamplesLen
+}
+
+// Userdata returns the Userdata of `premix`, from before any mix was made from it
+func Userdata(premix *output.PremixData) any {
+	if t, ok := premix.Userdata.(*TrackerOutput); ok {
+		return t.Userdata
+	}
+	return premix.Userdata
+}
+
+// WithTrackerChannels carries the tracker channels of `source` along in `premix`, which holds a mix made from it.
+// A `source` of nil means `premix` holds audio which was held back from earlier on (such as by a look-ahead
+// limiter), so it doesn't cover any of the song.
+func WithTrackerChannels(premix, source *output.PremixData) *output.PremixData {
+	t := TrackerOutput{
+		Userdata: Userdata(premix),
+	}
+	if channels, samplesLen := TrackerChannels(source); len(channels) > 0 || samplesLen > 0 {
+		t.SamplesLen = samplesLen
+		t.Channels = make(mixing.ChannelData, len(channels))
+		for i, d := range channels {
+			// the mix of `premix` is already at the volume of the mixer
+			d.Volume *= source.MixerVolume
+			t.Channels[i] = d
+		}
+	}
+	premix.Userdata = &t
+	return premix
+}
@@ -0,0 +1,99 @@
+package common
+
+import "fmt"
+
+// SampleFormat is the format of the samples written by a (raw or wave) file output device
+type SampleFormat string
+
+const (
+	// SampleFormatS8 is signed 8-bit integer PCM
+	SampleFormatS8 = SampleFormat("s8")
+	// SampleFormatU8 is unsigned 8-bit integer PCM
+	SampleFormatU8 = SampleFormat("u8")
+	// SampleFormatS16LE is signed 16-bit little-endian integer PCM
+	SampleFormatS16LE = SampleFormat("s16le")
+	// SampleFormatS24LE is signed 24-bit little-endian integer PCM, packed into 3 bytes
+	SampleFormatS24LE = SampleFormat("s24le")
+	// SampleFormatS32LE is signed 32-bit little-endian integer PCM
+	SampleFormatS32LE = SampleFormat("s32le")
+	// SampleFormatF32LE is 32-bit little-endian IEEE float PCM
+	SampleFormatF32LE = SampleFormat("f32le")
+)
+
+// GetSampleFormat returns the sample format named in the settings,
+// or the one matching the bits per sample when none is named
+func GetSampleFormat(settings Settings) (SampleFormat, error) {
+	switch f := SampleFormat(settings.SampleFormat); f {
+	case SampleFormatS8, SampleFormatU8, SampleFormatS16LE, SampleFormatS24LE, SampleFormatS32LE, SampleFormatF32LE:
+		return f, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unsupported sample format %q", settings.SampleFormat)
+	}
+
+	switch settings.BitsPerSample {
+	case 8:
+		return SampleFormatU8, nil
+	case 16:
+		return SampleFormatS16LE, nil
+	case 24:
+		return SampleFormatS24LE, nil
+	case 32:
+		return SampleFormatF32LE, nil
+	}
+	return "", fmt.Errorf("unsupported bits per sample: %d", settings.BitsPerSample)
+}
+
+// BitsPerSample returns the size of a single sample in bits
+func (f SampleFormat) BitsPerSample() int {
+	switch f {
+	case SampleFormatS8, SampleFormatU8:
+		return 8
+	case SampleFormatS16LE:
+		return 16
+	case SampleFormatS24LE:
+		return 24
+	case SampleFormatS32LE, SampleFormatF32LE:
+		return 32
+	}
+	return 0
+}
+
+// IsFloat returns true if the samples are floating point values
+func (f SampleFormat) IsFloat() bool {
+	return f == SampleFormatF32LE
+}
+
+// quantizedBits returns the number of bits the samples of an integer format get quantized to.
+// The mixer works in 32-bit floats, so 24 bits already holds everything it has to offer.
+func (f SampleFormat) quantizedBits() int {
+	return min(f.BitsPerSample(), 24)
+}
+
+// pack interleaves `samples` samples of each channel of `data` (as made for the
+// format's quantized bits) into the bytes of the integer sample format
+func (f SampleFormat) pack(data [][]int32, samples int) []byte {
+	bits := f.quantizedBits()
+	lo, hi := -int32(1)<<(bits-1), int32(1)<<(bits-1)-1
+	size := f.BitsPerSample() / 8
+	out := make([]byte, 0, samples*len(data)*size)
+	for i := 0; i < samples; i++ {
+		for c := range data {
+			v := min(max(data[c][i], lo), hi)
+			switch f {
+			case SampleFormatS8:
+				out = append(out, byte(v))
+			case SampleFormatU8:
+				out = append(out, byte(v+0x80))
+			case SampleFormatS16LE:
+				out = append(out, byte(v), byte(v>>8))
+			case SampleFormatS24LE:
+				out = append(out, byte(v), byte(v>>8), byte(v>>16))
+			case SampleFormatS32LE:
+				v <<= 8
+				out = append(out, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+			}
+		}
+	}
+	return out
+}
@@ -1,12 +1,31 @@
 package common
 
+import (
+	"io"
+	"time"
+)
+
 // Settings is the settings for configuring an output device
 type Settings struct {
-	Name             string `pflag:"output" env:"output" pf:"O" usage:"output device"`
-	Channels         int    `pflag:"channels" env:"channels" pf:"c" usage:"channels"`
-	SamplesPerSecond int    `pflag:"sample-rate" env:"sample_rate" pf:"s" usage:"sample rate"`
-	BitsPerSample    int    `pflag:"bits-per-sample" env:"bits_per_sample" pf:"b" usage:"bits per sample"`
-	StereoSeparation int    `pflag:"stereo-separation" env:"stereo_separation" pf:"S" usage:"stereo separation (0-100)"`
-	Filepath         string `pflag:"output-file" env:"-" pf:"f" usage:"output filepath"`
+	Name             string        `pflag:"output" env:"output" pf:"O" usage:"output device (or a comma-separated list of devices to output to at once, each optionally followed by :output-file=, :bits-per-sample=, :sample-format= or :sink= overrides), e.g.: pulseaudio:<sink name>"`
+	Channels         int           `pflag:"channels" env:"channels" pf:"c" usage:"channels"`
+	SamplesPerSecond int           `pflag:"sample-rate" env:"sample_rate" pf:"s" usage:"sample rate"`
+	BitsPerSample    int           `pflag:"bits-per-sample" env:"bits_per_sample" pf:"b" usage:"bits per sample"`
+	StereoSeparation int           `pflag:"stereo-separation" env:"stereo_separation" pf:"S" usage:"stereo separation (0-100)"`
+	Filepath         string        `pflag:"output-file" env:"-" pf:"f" usage:"output filepath"`
+	SampleFormat     string        `pflag:"sample-format" env:"sample_format" usage:"sample format of the pipe and wave outputs (s8, u8, s16le, s24le, s32le, f32le; blank = based on bits per sample)"`
+	Stems            bool          `pflag:"stems" env:"stems" usage:"write each tracker channel to its own file, named after the output filepath (file output only)"`
+	StemsMaster      bool          `pflag:"stems-master" env:"stems_master" usage:"also write the full mix to the output filepath when writing stems"`
+	CueSongs         bool          `pflag:"cue-songs" env:"cue_songs" usage:"also mark the start of every playlist entry in the cue points of wave output"`
+	Dither           string        `pflag:"dither" env:"dither" usage:"dither applied when reducing the mix to integer samples (none, tpdf, shaped, lipshitz)"`
+	HTTPListen       string        `pflag:"http-listen" env:"http_listen" usage:"address the http output device listens on"`
+	Picture          string        `pflag:"picture" env:"picture" usage:"image file to embed as the front cover of flac output"`
+	NullRealTime     bool          `pflag:"null-realtime" env:"null_realtime" usage:"discard audio at real-time speed on the null output device, instead of as fast as it renders"`
+	Benchmark        bool          `pflag:"benchmark" env:"benchmark" usage:"report how quickly the audio rendered when the null output device finishes"`
+	Sink             string        `pflag:"sink" env:"sink" usage:"sink of the pulseaudio output device to play to (blank = default sink; see 'device list')"`
+	Latency          time.Duration `pflag:"latency" env:"latency" usage:"how far behind the audio may be heard on the pulseaudio output device"`
+	BufferSize       int           `pflag:"buffer-size" env:"buffer_size" usage:"size of the pulseaudio output device's buffer in samples (overrides latency when set)"`
 	OnRowOutput      WrittenCallback
+	// Messages is where the devices write what they have to report (if anything)
+	Messages io.Writer
 }
@@ -0,0 +1,10 @@
+package common
+
+// SongInfo describes a song that is about to be output. It is sent to the output device
+// as the Userdata of an empty premix, right before the first of the song's own output.
+type SongInfo struct {
+	Title    string
+	Artist   string
+	Filepath string
+	Format   string
+}
@@ -0,0 +1,9 @@
+package common
+
+// Target is one of the things an output device can output to, such as one of the sinks of a sound server
+type Target struct {
+	// Name is what the target is selected by, following the device name (e.g.: `pulseaudio:<name>`)
+	Name        string
+	Description string
+	IsDefault   bool
+}
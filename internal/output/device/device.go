@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
 	"github.com/gotracker/playback/output"
@@ -26,11 +27,67 @@ type kindGetter interface {
 	GetKind() deviceCommon.Kind
 }
 
+type pauser interface {
+	Pause() error
+	Resume() error
+}
+
+type pauseChecker interface {
+	CanPause() bool
+}
+
 type createOutputDeviceFunc func(settings deviceCommon.Settings) (Device, error)
 
 type deviceDetails struct {
 	create createOutputDeviceFunc
 	Kind   deviceCommon.Kind
+	// the option the device's name may be followed by without naming it (e.g.: `pulseaudio:<sink name>`)
+	defaultOption string
+	// lists what the device can output to, for devices that can output to more than one thing
+	listTargets func() ([]deviceCommon.Target, error)
+	// returns the formats the device can play when set up with `settings` (nil = the formats the mixer can make)
+	capabilities func(settings deviceCommon.Settings) deviceCommon.Capabilities
+}
+
+// getCapabilities returns the formats the device can play when set up with `settings`
+func (d deviceDetails) getCapabilities(settings deviceCommon.Settings) deviceCommon.Capabilities {
+	if d.capabilities == nil {
+		return deviceCommon.Capabilities{
+			MinSampleRate: 1,
+			Channels:      deviceCommon.MixerChannels,
+		}
+	}
+	return d.capabilities(settings)
+}
+
+// fixedCapabilities returns the capabilities of a device that can play the same formats however it's set up
+func fixedCapabilities(caps deviceCommon.Capabilities) func(deviceCommon.Settings) deviceCommon.Capabilities {
+	return func(deviceCommon.Settings) deviceCommon.Capabilities {
+		return caps
+	}
+}
+
+// GetCapabilities returns the formats the device `name` can play
+// (for the file device, it's what any of the file formats can hold)
+func GetCapabilities(name string) (deviceCommon.Capabilities, error) {
+	details, ok := Map[name]
+	if !ok {
+		return deviceCommon.Capabilities{}, fmt.Errorf("%w: %s", ErrDeviceNotSupported, name)
+	}
+	return details.getCapabilities(deviceCommon.Settings{}), nil
+}
+
+// ListTargets returns what the device `name` can output to (e.g.: the sinks of the pulseaudio device),
+// if it can output to more than one thing
+func ListTargets(name string) ([]deviceCommon.Target, error) {
+	details, ok := Map[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotSupported, name)
+	}
+	if details.listTargets == nil {
+		return nil, nil
+	}
+	return details.listTargets()
 }
 
 // GetKind returns the kind for the passed in device
@@ -41,6 +98,35 @@ func GetKind(d Device) deviceCommon.Kind {
 	return deviceCommon.KindNone
 }
 
+// CanPause returns whether the output of the passed in device can be paused.
+// A sound card has to be able to pause itself, otherwise it runs dry (and stutters) while nothing is sent to it,
+// whereas any other kind of device just waits for more.
+func CanPause(d Device) bool {
+	if dev, ok := d.(pauseChecker); ok {
+		return dev.CanPause()
+	}
+	if _, ok := d.(pauser); ok {
+		return true
+	}
+	return GetKind(d) != deviceCommon.KindSoundCard
+}
+
+// Pause pauses the output of the passed in device, if it supports it
+func Pause(d Device) error {
+	if dev, ok := d.(pauser); ok {
+		return dev.Pause()
+	}
+	return nil
+}
+
+// Resume resumes the output of the passed in device, if it supports it
+func Resume(d Device) error {
+	if dev, ok := d.(pauser); ok {
+		return dev.Resume()
+	}
+	return nil
+}
+
 var (
 	// Map is the mapping of device name to device details
 	Map = make(map[string]deviceDetails)
@@ -48,7 +134,19 @@ var (
 
 // CreateOutputDevice creates an output device based on the provided settings
 func CreateOutputDevice(settings deviceCommon.Settings) (Device, error) {
+	if strings.ContainsAny(settings.Name, teeSeparator+teeOptionSeparator) {
+		specs, err := parseDeviceSpecs(settings)
+		if err != nil {
+			return nil, err
+		}
+		if len(specs) == 1 {
+			return CreateOutputDevice(specs[0].settings)
+		}
+		return newTeeDevice(specs)
+	}
+
 	if details, ok := Map[settings.Name]; ok && details.create != nil {
+		negotiateBitsPerSample(details, &settings)
 		dev, err := details.create(settings)
 		if err != nil {
 			return nil, err
@@ -10,7 +10,6 @@ import (
 
 	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
 	"github.com/gotracker/playback/mixing"
-	"github.com/gotracker/playback/mixing/sampling"
 	"github.com/gotracker/playback/output"
 	directsound "github.com/heucuva/go-directsound"
 	win32 "github.com/heucuva/go-win32"
@@ -27,8 +26,8 @@ type dsoundDevice struct {
 	lpdsbPrimary *directsound.Buffer
 	wfx          *winmm.WAVEFORMATEX
 
-	mix     mixing.Mixer
-	sampFmt sampling.Format
+	flattener *deviceCommon.Flattener
+	sampFmt   deviceCommon.SampleFormat
 }
 
 // Name returns the device name
@@ -41,20 +40,23 @@ func (dsoundDevice) GetKind() deviceCommon.Kind {
 }
 
 func newDSoundDevice(settings deviceCommon.Settings) (Device, error) {
+	flattener, err := deviceCommon.NewFlattener(settings)
+	if err != nil {
+		return nil, err
+	}
+
 	d := dsoundDevice{
 		device: device{
 			onRowOutput: settings.OnRowOutput,
 		},
-		mix: mixing.Mixer{
-			Channels: settings.Channels,
-		},
+		flattener: flattener,
 	}
 
 	switch settings.BitsPerSample {
 	case 8:
-		d.sampFmt = sampling.Format8BitUnsigned
+		d.sampFmt = deviceCommon.SampleFormatU8
 	case 16:
-		d.sampFmt = sampling.Format16BitLESigned
+		d.sampFmt = deviceCommon.SampleFormatS16LE
 	}
 
 	preferredDeviceName := ""
@@ -97,7 +99,7 @@ type playbackBuffer struct {
 	writePos   int
 }
 
-func (p *playbackBuffer) Add(mix *mixing.Mixer, row *output.PremixData, pos int, size int, blockAlign int, panmixer mixing.PanMixer, format sampling.Format) (int, error) {
+func (p *playbackBuffer) Add(flattener *deviceCommon.Flattener, row *output.PremixData, pos int, size int, blockAlign int, format deviceCommon.SampleFormat) (int, error) {
 	remaining := p.maxSamples - p.writePos
 	samples := row.SamplesLen - pos
 	if samples >= remaining {
@@ -120,7 +122,11 @@ func (p *playbackBuffer) Add(mix *mixing.Mixer, row *output.PremixData, pos int,
 		rear := make([]byte, rem*blockAlign)
 		writeSegs = append(writeSegs, rear)
 	}
-	mix.FlattenTo(writeSegs, panmixer.NumChannels(), row.SamplesLen, row.Data, row.MixerVolume, format)
+	mixedData := flattener.Flatten(row, format)
+	for _, seg := range writeSegs {
+		n := copy(seg, mixedData)
+		mixedData = mixedData[n:]
+	}
 	if err := p.buffer.Unlock(segments); err != nil {
 		return 0, err
 	}
@@ -138,7 +144,7 @@ func (d *dsoundDevice) PlayWithCtx(ctx context.Context, in <-chan *output.Premix
 	maxOutstanding := 3
 	maxOutstandingEvents := 1000
 
-	panmixer := mixing.GetPanMixer(d.mix.Channels)
+	panmixer := mixing.GetPanMixer(d.flattener.Channels())
 	if panmixer == nil {
 		return errors.New("invalid pan mixer - check channel count")
 	}
@@ -223,7 +229,7 @@ func (d *dsoundDevice) PlayWithCtx(ctx context.Context, in <-chan *output.Premix
 					})
 				}
 				for size > 0 {
-					n, err := currentBuffer.Add(&d.mix, row, pos, row.SamplesLen, blockAlign, panmixer, d.sampFmt)
+					n, err := currentBuffer.Add(d.flattener, row, pos, row.SamplesLen, blockAlign, d.sampFmt)
 					size -= n
 					pos += n
 					if err != nil {
@@ -313,5 +319,11 @@ func init() {
 	Map[dsoundName] = deviceDetails{
 		create: newDSoundDevice,
 		Kind:   deviceCommon.KindSoundCard,
+		capabilities: fixedCapabilities(deviceCommon.Capabilities{
+			MinSampleRate: 100,    // = DSBFREQUENCY_MIN
+			MaxSampleRate: 200000, // = DSBFREQUENCY_MAX
+			BitsPerSample: []int{8, 16},
+			Channels:      deviceCommon.MixerChannels,
+		}),
 	}
 }
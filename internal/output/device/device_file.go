@@ -54,6 +54,9 @@ func (d *fileDevice) Close() error {
 func newFileDevice(settings deviceCommon.Settings) (Device, error) {
 	ext := strings.ToLower(path.Ext(settings.Filepath))
 	if factory, ok := deviceFile.GetFileDevice(ext); ok && factory != nil {
+		if settings.Stems {
+			factory = deviceFile.NewStemsFactory(factory)
+		}
 		processor, err := factory(settings)
 		if err != nil {
 			return nil, err
@@ -72,7 +75,11 @@ func newFileDevice(settings deviceCommon.Settings) (Device, error) {
 
 func init() {
 	Map[fileName] = deviceDetails{
-		create: newFileDevice,
-		Kind:   deviceCommon.KindFile,
+		create:        newFileDevice,
+		Kind:          deviceCommon.KindFile,
+		defaultOption: "output-file",
+		capabilities: func(settings deviceCommon.Settings) deviceCommon.Capabilities {
+			return deviceFile.GetFileCapabilities(strings.ToLower(path.Ext(settings.Filepath)))
+		},
 	}
 }
@@ -0,0 +1,379 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotracker/playback/output"
+
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+	deviceFile "github.com/gotracker/gotracker/internal/output/device/file"
+)
+
+const (
+	httpName = "http"
+
+	// the streams are served at `/stream` followed by the extension of their format (e.g.: `/stream.wav`)
+	httpStreamPathPrefix = "/stream"
+	httpStreamName       = "Gotracker"
+
+	// how many bytes of the stream come between the ICY metadata blocks
+	httpIcyMetaInt = 16000
+	// how far ahead of real time the stream may get, so that listeners have something in hand
+	httpLeadTime = 500 * time.Millisecond
+	// how many pieces of the stream a listener may fall behind by before it gets dropped
+	httpListenerBufferSize = 256
+)
+
+// httpDevice serves the mix as endless streams to any number of listeners over HTTP, at the pace
+// it would be heard at, along with ICY ("shoutcast") metadata naming the song being played
+type httpDevice struct {
+	device
+	pacer realTimePacer
+
+	streams []*httpStream
+	server  *http.Server
+
+	titleMu sync.RWMutex
+	title   string
+}
+
+// httpStream is one format of the stream, which is only encoded while someone's listening to it
+type httpStream struct {
+	path     string
+	factory  deviceFile.StreamEncoderFactory
+	settings deviceCommon.Settings
+
+	mu        sync.Mutex
+	enc       deviceFile.StreamEncoder
+	listeners map[*httpListener]struct{}
+}
+
+type httpListener struct {
+	pieces chan []byte
+}
+
+func (*httpDevice) GetKind() deviceCommon.Kind {
+	return deviceCommon.KindStream
+}
+
+// Name returns the device name
+func (*httpDevice) Name() string {
+	return httpName
+}
+
+func newHTTPDevice(settings deviceCommon.Settings) (Device, error) {
+	d := httpDevice{
+		device: device{
+			onRowOutput: settings.OnRowOutput,
+		},
+		pacer: realTimePacer{
+			samplesPerSecond: settings.SamplesPerSecond,
+			lead:             httpLeadTime,
+		},
+	}
+
+	mux := http.NewServeMux()
+	for _, ext := range deviceFile.GetStreamEncoderExtensions() {
+		factory, _ := deviceFile.GetStreamEncoder(ext)
+		if _, err := factory(settings); err != nil {
+			// the format can't hold the audio as it's been set up
+			continue
+		}
+		s := &httpStream{
+			path:      httpStreamPathPrefix + ext,
+			factory:   factory,
+			settings:  settings,
+			listeners: make(map[*httpListener]struct{}),
+		}
+		d.streams = append(d.streams, s)
+		mux.HandleFunc(s.path, d.serveStream(s))
+	}
+	if len(d.streams) == 0 {
+		return nil, errors.New("no stream formats support the output settings")
+	}
+	mux.HandleFunc("/", d.serveIndex)
+
+	l, err := net.Listen("tcp", settings.HTTPListen)
+	if err != nil {
+		return nil, err
+	}
+	d.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		_ = d.server.Serve(l)
+	}()
+
+	return &d, nil
+}
+
+// serveIndex lists the streams
+func (d *httpDevice) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, s := range d.streams {
+		fmt.Fprintf(w, "http://%s%s\n", r.Host, s.path)
+	}
+}
+
+func (d *httpDevice) serveStream(s *httpStream) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, enc, err := s.join()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer s.leave(l)
+
+		h := w.Header()
+		h.Set("Content-Type", enc.ContentType())
+		h.Set("Cache-Control", "no-cache, no-store")
+		h.Set("icy-name", httpStreamName)
+		var out io.Writer = w
+		if r.Header.Get("Icy-MetaData") == "1" {
+			h.Set("icy-metaint", strconv.Itoa(httpIcyMetaInt))
+			out = &icyWriter{
+				w:          w,
+				untilMeta:  httpIcyMetaInt,
+				nowPlaying: d.nowPlaying,
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		flusher, _ := w.(http.Flusher)
+		if _, err := out.Write(enc.Header()); err != nil {
+			return
+		}
+		for {
+			if flusher != nil {
+				flusher.Flush()
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case piece, ok := <-l.pieces:
+				if !ok {
+					return
+				}
+				if _, err := out.Write(piece); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// join adds a listener to the stream, starting up its encoder if nobody else was listening
+func (s *httpStream) join() (*httpListener, deviceFile.StreamEncoder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.enc == nil {
+		enc, err := s.factory(s.settings)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.enc = enc
+	}
+
+	l := &httpListener{
+		pieces: make(chan []byte, httpListenerBufferSize),
+	}
+	s.listeners[l] = struct{}{}
+	return l, s.enc, nil
+}
+
+// leave removes a listener from the stream
+func (s *httpStream) leave(l *httpListener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drop(l)
+}
+
+// drop removes a listener from the stream, stopping the encoder once nobody's listening.
+// The stream must be locked.
+func (s *httpStream) drop(l *httpListener) {
+	if _, ok := s.listeners[l]; !ok {
+		return
+	}
+	delete(s.listeners, l)
+	close(l.pieces)
+	if len(s.listeners) == 0 {
+		s.enc = nil
+	}
+}
+
+// broadcast encodes `row` and sends it to everyone listening to the stream
+func (s *httpStream) broadcast(row *output.PremixData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.enc == nil {
+		return nil
+	}
+	piece, err := s.enc.Encode(row)
+	if err != nil {
+		return err
+	}
+	if len(piece) == 0 {
+		return nil
+	}
+	for l := range s.listeners {
+		select {
+		case l.pieces <- piece:
+		default:
+			// the listener can't keep up
+			s.drop(l)
+		}
+	}
+	return nil
+}
+
+// nowPlaying returns the title of the song being played
+func (d *httpDevice) nowPlaying() string {
+	d.titleMu.RLock()
+	defer d.titleMu.RUnlock()
+	return d.title
+}
+
+func (d *httpDevice) update(userdata any) {
+	song, ok := userdata.(*deviceCommon.SongInfo)
+	if !ok {
+		return
+	}
+	title := song.Title
+	if title == "" {
+		title = filepath.Base(song.Filepath)
+	}
+	if song.Artist != "" {
+		title = song.Artist + " - " + title
+	}
+
+	d.titleMu.Lock()
+	defer d.titleMu.Unlock()
+	d.title = title
+}
+
+// Play starts the http output device playing
+func (d *httpDevice) Play(in <-chan *output.PremixData) error {
+	return d.PlayWithCtx(context.Background(), in)
+}
+
+// PlayWithCtx starts the http output device playing
+func (d *httpDevice) PlayWithCtx(ctx context.Context, in <-chan *output.PremixData) error {
+	myCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for {
+		select {
+		case <-myCtx.Done():
+			return myCtx.Err()
+		case row, ok := <-in:
+			if !ok {
+				return nil
+			}
+			d.update(deviceCommon.Userdata(row))
+			for _, s := range d.streams {
+				if err := s.broadcast(row); err != nil {
+					return err
+				}
+			}
+			if d.onRowOutput != nil {
+				d.onRowOutput(deviceCommon.KindStream, row)
+			}
+			if err := d.pacer.pace(myCtx, row.SamplesLen); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close closes the http output device
+func (d *httpDevice) Close() error {
+	for _, s := range d.streams {
+		s.mu.Lock()
+		for l := range s.listeners {
+			s.drop(l)
+		}
+		s.mu.Unlock()
+	}
+	if d.server != nil {
+		return d.server.Close()
+	}
+	return nil
+}
+
+// icyWriter slips ICY metadata blocks in between the bytes of a stream
+type icyWriter struct {
+	w          io.Writer
+	untilMeta  int
+	nowPlaying func() string
+	sent       string
+}
+
+func (iw *icyWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		n, err := iw.w.Write(p[:min(len(p), iw.untilMeta)])
+		written += n
+		iw.untilMeta -= n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+
+		if iw.untilMeta == 0 {
+			if _, err := iw.w.Write(iw.metadata()); err != nil {
+				return written, err
+			}
+			iw.untilMeta = httpIcyMetaInt
+		}
+	}
+	return written, nil
+}
+
+// metadata returns the next metadata block, which is empty unless the title has changed
+func (iw *icyWriter) metadata() []byte {
+	title := iw.nowPlaying()
+	if title == iw.sent {
+		return []byte{0}
+	}
+	iw.sent = title
+
+	// the title is quoted, so it can't hold any quotes of its own
+	text := "StreamTitle='" + strings.ReplaceAll(title, "'", "’") + "';"
+	// the length is counted in 16-byte units
+	blocks := min((len(text)+15)/16, 255)
+	meta := make([]byte, 1+blocks*16)
+	meta[0] = byte(blocks)
+	copy(meta[1:], text)
+	return meta
+}
+
+func init() {
+	Map[httpName] = deviceDetails{
+		create: newHTTPDevice,
+		Kind:   deviceCommon.KindStream,
+		// the formats that can't hold the audio aren't served, so it only takes one of them
+		capabilities: func(deviceCommon.Settings) deviceCommon.Capabilities {
+			return deviceFile.GetStreamCapabilities()
+		},
+	}
+}
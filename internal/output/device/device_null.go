@@ -0,0 +1,168 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/gotracker/playback/output"
+	"github.com/gotracker/playback/player/render"
+
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+)
+
+const (
+	nullName = "null"
+
+	// at real-time speed, the null device gets no further ahead than a sound card's buffer would
+	nullLeadTime = 100 * time.Millisecond
+)
+
+// nullDevice mixes the audio and throws it away, either as fast as it can be rendered or at the pace
+// it would be heard at, optionally reporting how quickly it was rendered when it's done
+type nullDevice struct {
+	device
+	flattener *deviceCommon.Flattener
+	sampFmt   deviceCommon.SampleFormat
+
+	realTime bool
+	pacer    realTimePacer
+
+	benchmark bool
+	messages  io.Writer
+}
+
+// nullBenchmark holds the rendering statistics of the null device
+type nullBenchmark struct {
+	start   time.Time
+	rows    int // the pattern rows played, each of which takes one tick or more to render
+	samples int
+	mallocs uint64
+}
+
+// GetKind returns the kind of device the null device stands in for: a sound card at real-time speed,
+// or a file otherwise, as that's what renders as fast as it can
+func (d *nullDevice) GetKind() deviceCommon.Kind {
+	if d.realTime {
+		return deviceCommon.KindSoundCard
+	}
+	return deviceCommon.KindFile
+}
+
+// Name returns the device name
+func (*nullDevice) Name() string {
+	return nullName
+}
+
+func newNullDevice(settings deviceCommon.Settings) (Device, error) {
+	flattener, err := deviceCommon.NewFlattener(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	sampFmt, err := deviceCommon.GetSampleFormat(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	d := nullDevice{
+		device: device{
+			onRowOutput: settings.OnRowOutput,
+		},
+		flattener: flattener,
+		sampFmt:   sampFmt,
+		realTime:  settings.NullRealTime,
+		pacer: realTimePacer{
+			samplesPerSecond: settings.SamplesPerSecond,
+			lead:             nullLeadTime,
+		},
+		benchmark: settings.Benchmark,
+		messages:  settings.Messages,
+	}
+	return &d, nil
+}
+
+// Play starts the null output device playing
+func (d *nullDevice) Play(in <-chan *output.PremixData) error {
+	return d.PlayWithCtx(context.Background(), in)
+}
+
+// PlayWithCtx starts the null output device playing
+func (d *nullDevice) PlayWithCtx(ctx context.Context, in <-chan *output.PremixData) error {
+	myCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stats *nullBenchmark
+	if d.benchmark {
+		stats = &nullBenchmark{}
+		defer d.report(stats)
+	}
+
+	for {
+		select {
+		case <-myCtx.Done():
+			return myCtx.Err()
+		case row, ok := <-in:
+			if !ok {
+				return nil
+			}
+			if stats != nil && stats.start.IsZero() {
+				// the clock starts with the first row, so it doesn't count the time taken to load the song
+				var ms runtime.MemStats
+				runtime.ReadMemStats(&ms)
+				stats.start = time.Now()
+				stats.mallocs = ms.Mallocs
+			}
+
+			_ = d.flattener.Flatten(row, d.sampFmt)
+			if stats != nil {
+				if r, ok := deviceCommon.Userdata(row).(*render.RowRender); ok && r != nil && r.Tick == 0 {
+					stats.rows++
+				}
+				stats.samples += row.SamplesLen
+			}
+			if d.onRowOutput != nil {
+				d.onRowOutput(d.GetKind(), row)
+			}
+			if d.realTime {
+				if err := d.pacer.pace(myCtx, row.SamplesLen); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// report writes out the rendering statistics
+func (d *nullDevice) report(stats *nullBenchmark) {
+	if d.messages == nil || stats.start.IsZero() {
+		return
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	wall := time.Since(stats.start)
+	rendered := time.Duration(stats.samples) * time.Second / time.Duration(d.pacer.samplesPerSecond)
+
+	fmt.Fprintln(d.messages)
+	fmt.Fprintf(d.messages, "Benchmark: rendered %v in %v (%.2fx real time), %d rows, %.1f allocations per row\n",
+		rendered.Round(time.Millisecond),
+		wall.Round(time.Millisecond),
+		rendered.Seconds()/wall.Seconds(),
+		stats.rows,
+		float64(ms.Mallocs-stats.mallocs)/float64(max(stats.rows, 1)))
+}
+
+// Close closes the null output device
+func (d *nullDevice) Close() error {
+	return nil
+}
+
+func init() {
+	Map[nullName] = deviceDetails{
+		create: newNullDevice,
+		Kind:   deviceCommon.KindFile, // unless set to play in real time, it renders as fast as it can, like a file
+	}
+}
@@ -0,0 +1,155 @@
+package device
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/output"
+
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+)
+
+const (
+	pipeName = "pipe"
+
+	// pipeStdoutFilepath is the output filepath that sends the pipe device's audio to the standard output
+	pipeStdoutFilepath = "-"
+)
+
+// pipeDevice streams interleaved raw PCM to the standard output or to a file (such as a FIFO),
+// without ever seeking back, so it works with anything that reads a stream
+type pipeDevice struct {
+	device
+	flattener *deviceCommon.Flattener
+	sampFmt   deviceCommon.SampleFormat
+
+	f io.WriteCloser
+	w *bufio.Writer
+}
+
+// WritesToStdout returns true if the output device described by `settings` writes its audio to
+// the standard output, in which case nothing else should be written there
+func WritesToStdout(settings deviceCommon.Settings) bool {
+	specs, err := parseDeviceSpecs(settings)
+	if err != nil {
+		return false
+	}
+	for _, spec := range specs {
+		if spec.settings.Name == pipeName && spec.settings.Filepath == pipeStdoutFilepath {
+			return true
+		}
+	}
+	return false
+}
+
+func (pipeDevice) GetKind() deviceCommon.Kind {
+	return deviceCommon.KindFile
+}
+
+// Name returns the device name
+func (pipeDevice) Name() string {
+	return pipeName
+}
+
+func newPipeDevice(settings deviceCommon.Settings) (Device, error) {
+	flattener, err := deviceCommon.NewFlattener(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	sampFmt, err := deviceCommon.GetSampleFormat(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	d := pipeDevice{
+		device: device{
+			onRowOutput: settings.OnRowOutput,
+		},
+		flattener: flattener,
+		sampFmt:   sampFmt,
+	}
+
+	if settings.Filepath == pipeStdoutFilepath {
+		d.f = os.Stdout
+	} else {
+		// no O_TRUNC or seeking here, as a FIFO supports neither
+		f, err := os.OpenFile(settings.Filepath, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			if err := f.Truncate(0); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+		d.f = f
+	}
+	d.w = bufio.NewWriter(d.f)
+
+	return &d, nil
+}
+
+// Play starts the pipe output device playing
+func (d *pipeDevice) Play(in <-chan *output.PremixData) error {
+	return d.PlayWithCtx(context.Background(), in)
+}
+
+// PlayWithCtx starts the pipe output device playing
+func (d *pipeDevice) PlayWithCtx(ctx context.Context, in <-chan *output.PremixData) error {
+	panmixer := mixing.GetPanMixer(d.flattener.Channels())
+	if panmixer == nil {
+		return errors.New("invalid pan mixer - check channel count")
+	}
+
+	myCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for {
+		select {
+		case <-myCtx.Done():
+			return myCtx.Err()
+		case row, ok := <-in:
+			if !ok {
+				return d.w.Flush()
+			}
+			if _, err := d.w.Write(d.flattener.Flatten(row, d.sampFmt)); err != nil {
+				return err
+			}
+			if d.onRowOutput != nil {
+				d.onRowOutput(deviceCommon.KindFile, row)
+			}
+		}
+	}
+}
+
+// Close closes the pipe output device
+func (d *pipeDevice) Close() error {
+	err := d.w.Flush()
+	if d.f == os.Stdout {
+		return err
+	}
+	if cerr := d.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func init() {
+	Map[pipeName] = deviceDetails{
+		create:        newPipeDevice,
+		Kind:          deviceCommon.KindFile,
+		defaultOption: "output-file",
+		capabilities: fixedCapabilities(deviceCommon.Capabilities{
+			MinSampleRate: 1,
+			BitsPerSample: []int{8, 16, 24, 32},
+			Channels:      deviceCommon.MixerChannels,
+			SampleFormats: true,
+		}),
+	}
+}
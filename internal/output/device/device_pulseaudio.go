@@ -6,51 +6,90 @@ package device
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/gotracker/playback/mixing"
-	"github.com/gotracker/playback/mixing/sampling"
 	"github.com/gotracker/playback/output"
 
 	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
 	"github.com/gotracker/gotracker/internal/output/device/pulseaudio"
 )
 
-const pulseaudioName = "pulseaudio"
+const (
+	pulseaudioName = "pulseaudio"
+	// the name the client goes by, as shown by volume control applications
+	pulseaudioAppName = "Gotracker"
+
+	// how long to wait before trying to reconnect to the server, doubling with each failed attempt up to the max
+	pulseaudioReconnectDelay    = 250 * time.Millisecond
+	pulseaudioReconnectMaxDelay = 10 * time.Second
+	// how often underruns get reported, at most
+	pulseaudioUnderrunReportInterval = 10 * time.Second
+)
 
 type pulseaudioDevice struct {
 	device
-	mix     mixing.Mixer
-	sampFmt sampling.Format
-	pa      *pulseaudio.Client
+	flattener *deviceCommon.Flattener
+	sampFmt   deviceCommon.SampleFormat
+	cfg       pulseaudio.Config
+	messages  io.Writer
+
+	// the client is replaced whenever the connection to the server is lost
+	mu     sync.Mutex
+	pa     *pulseaudio.Client
+	paused bool
+	media  *pulseaudio.MediaInfo
+
+	// underruns of the clients that have been replaced
+	pastUnderruns    int
+	reportedUnderrun int
+	lastReport       time.Time
 }
 
-func (pulseaudioDevice) GetKind() deviceCommon.Kind {
+func (*pulseaudioDevice) GetKind() deviceCommon.Kind {
 	return deviceCommon.KindSoundCard
 }
 
 // Name returns the device name
-func (pulseaudioDevice) Name() string {
+func (*pulseaudioDevice) Name() string {
 	return pulseaudioName
 }
 
 func newPulseAudioDevice(settings deviceCommon.Settings) (Device, error) {
+	flattener, err := deviceCommon.NewFlattener(settings)
+	if err != nil {
+		return nil, err
+	}
+
 	d := pulseaudioDevice{
 		device: device{
 			onRowOutput: settings.OnRowOutput,
 		},
-		mix: mixing.Mixer{
-			Channels: settings.Channels,
+		flattener: flattener,
+		messages:  settings.Messages,
+		cfg: pulseaudio.Config{
+			AppName:       pulseaudioAppName,
+			Sink:          settings.Sink,
+			SampleRate:    settings.SamplesPerSecond,
+			Channels:      settings.Channels,
+			BitsPerSample: settings.BitsPerSample,
+			Latency:       settings.Latency,
+			BufferSize:    settings.BufferSize,
 		},
 	}
 
 	switch settings.BitsPerSample {
 	case 8:
-		d.sampFmt = sampling.Format8BitUnsigned
+		d.sampFmt = deviceCommon.SampleFormatU8
 	case 16:
-		d.sampFmt = sampling.Format16BitLESigned
+		d.sampFmt = deviceCommon.SampleFormatS16LE
 	}
 
-	play, err := pulseaudio.New("Music", settings.SamplesPerSecond, settings.Channels, settings.BitsPerSample)
+	play, err := pulseaudio.New(d.cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +105,7 @@ func (d *pulseaudioDevice) Play(in <-chan *output.PremixData) error {
 
 // PlayWithCtx starts the wave output device playing
 func (d *pulseaudioDevice) PlayWithCtx(ctx context.Context, in <-chan *output.PremixData) error {
-	panmixer := mixing.GetPanMixer(d.mix.Channels)
+	panmixer := mixing.GetPanMixer(d.flattener.Channels())
 	if panmixer == nil {
 		return errors.New("invalid pan mixer - check channel count")
 	}
@@ -82,26 +121,192 @@ func (d *pulseaudioDevice) PlayWithCtx(ctx context.Context, in <-chan *output.Pr
 			if !ok {
 				return nil
 			}
-			mixedData := d.mix.Flatten(row.SamplesLen, row.Data, row.MixerVolume, d.sampFmt)
-			d.pa.Output(mixedData)
+			d.update(deviceCommon.Userdata(row))
+			mixedData := d.flattener.Flatten(row, d.sampFmt)
+			if err := d.output(myCtx, mixedData); err != nil {
+				return err
+			}
 			if d.onRowOutput != nil {
 				d.onRowOutput(deviceCommon.KindSoundCard, row)
 			}
+			d.reportUnderruns(false)
 		}
 	}
 }
 
+// output plays `data`, reconnecting to the server (and trying again) if the connection to it is lost.
+// The rows stop being taken in while it reconnects, so the player holds its position until it can carry on.
+func (d *pulseaudioDevice) output(ctx context.Context, data []byte) error {
+	for {
+		d.mu.Lock()
+		pa := d.pa
+		d.mu.Unlock()
+
+		err := pa.Output(ctx, data)
+		if !errors.Is(err, pulseaudio.ErrDisconnected) {
+			return err
+		}
+		if err := d.reconnect(ctx, err); err != nil {
+			return err
+		}
+	}
+}
+
+// reconnect replaces the client that lost its connection to the server with a new one,
+// backing off further with each failed attempt
+func (d *pulseaudioDevice) reconnect(ctx context.Context, cause error) error {
+	d.mu.Lock()
+	if d.pa != nil {
+		d.pastUnderruns += d.pa.Underruns()
+		_ = d.pa.Close()
+		d.pa = nil
+	}
+	d.mu.Unlock()
+
+	d.printf("pulseaudio: %v - reconnecting\n", cause)
+	delay := pulseaudioReconnectDelay
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		pa, err := pulseaudio.New(d.cfg)
+		if err != nil {
+			delay = min(delay*2, pulseaudioReconnectMaxDelay)
+			d.printf("pulseaudio: could not reconnect (%v) - trying again in %v\n", err, delay)
+			continue
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.pa = pa
+		if d.paused {
+			pa.Pause()
+		}
+		if d.media != nil {
+			_ = pa.SetMediaInfo(*d.media)
+		}
+		d.printf("pulseaudio: reconnected\n")
+		return nil
+	}
+}
+
+// reportUnderruns reports how many times the server has run out of audio to play, if that's gone up
+// since the last report - which is only done every so often, unless `final` is set
+func (d *pulseaudioDevice) reportUnderruns(final bool) {
+	d.mu.Lock()
+	underruns := d.pastUnderruns
+	if d.pa != nil {
+		underruns += d.pa.Underruns()
+	}
+	d.mu.Unlock()
+
+	if underruns == d.reportedUnderrun || (!final && time.Since(d.lastReport) < pulseaudioUnderrunReportInterval) {
+		return
+	}
+	d.printf("pulseaudio: %d underrun(s) so far - the audio ran out before more arrived (a higher --latency may help)\n", underruns)
+	d.reportedUnderrun = underruns
+	d.lastReport = time.Now()
+}
+
+func (d *pulseaudioDevice) printf(format string, args ...any) {
+	if d.messages != nil {
+		fmt.Fprintf(d.messages, format, args...)
+	}
+}
+
+// update tells volume control applications about the song about to be played
+func (d *pulseaudioDevice) update(userdata any) {
+	song, ok := userdata.(*deviceCommon.SongInfo)
+	if !ok {
+		return
+	}
+	title := song.Title
+	if title == "" {
+		title = filepath.Base(song.Filepath)
+	}
+	media := pulseaudio.MediaInfo{
+		Title:    title,
+		Artist:   song.Artist,
+		Filename: song.Filepath,
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.media = &media
+	if d.pa != nil {
+		// it's only for show, so there's no need to stop playing if it doesn't work out
+		_ = d.pa.SetMediaInfo(media)
+	}
+}
+
+// Pause pauses the wave output device
+func (d *pulseaudioDevice) Pause() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.paused = true
+	if d.pa != nil {
+		d.pa.Pause()
+	}
+	return nil
+}
+
+// Resume resumes the wave output device
+func (d *pulseaudioDevice) Resume() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.paused = false
+	if d.pa != nil {
+		d.pa.Resume()
+	}
+	return nil
+}
+
 // Close closes the wave output device
 func (d *pulseaudioDevice) Close() error {
+	d.reportUnderruns(true)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	if d.pa != nil {
-		return d.pa.Close()
+		err := d.pa.Close()
+		d.pa = nil
+		return err
 	}
 	return nil
 }
 
+// listPulseAudioSinks lists the sinks that can be played to
+func listPulseAudioSinks() ([]deviceCommon.Target, error) {
+	sinks, err := pulseaudio.ListSinks(pulseaudioAppName)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]deviceCommon.Target, len(sinks))
+	for i, s := range sinks {
+		targets[i] = deviceCommon.Target{
+			Name:        s.ID,
+			Description: s.Description,
+			IsDefault:   s.IsDefault,
+		}
+	}
+	return targets, nil
+}
+
 func init() {
 	Map[pulseaudioName] = deviceDetails{
-		create: newPulseAudioDevice,
-		Kind:   deviceCommon.KindSoundCard,
+		create:        newPulseAudioDevice,
+		listTargets:   listPulseAudioSinks,
+		Kind:          deviceCommon.KindSoundCard,
+		defaultOption: "sink",
+		capabilities: fixedCapabilities(deviceCommon.Capabilities{
+			MinSampleRate: 1,
+			MaxSampleRate: 384000, // = PA_RATE_MAX
+			BitsPerSample: []int{8, 16},
+			Channels:      deviceCommon.MixerChannels,
+		}),
 	}
 }
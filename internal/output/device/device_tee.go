@@ -0,0 +1,256 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gotracker/playback/output"
+
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+)
+
+const (
+	// teeSeparator separates the devices of a tee in the output device name
+	teeSeparator = ","
+	// teeOptionSeparator separates a device's name from its options (and the options from each other)
+	teeOptionSeparator = ":"
+
+	teeBufferSize = 128
+)
+
+var (
+	// the more interactive a kind of device, the higher its precedence
+	teeKindPrecedence = map[deviceCommon.Kind]int{
+		deviceCommon.KindNone:      0,
+		deviceCommon.KindFile:      1,
+		deviceCommon.KindStream:    2,
+		deviceCommon.KindSoundCard: 3,
+	}
+)
+
+// teeDevice fans each row out to several devices at once, each flattening it in its own format
+// (e.g.: `-O pulseaudio,file:bits-per-sample=24 -f session.flac` to listen while recording)
+type teeDevice struct {
+	devices []Device
+}
+
+// deviceSpec is one device named in the output device name, along with the settings it gets
+type deviceSpec struct {
+	settings deviceCommon.Settings
+}
+
+// parseDeviceSpecs splits the output device name in `settings` into the devices it names.
+// Each device may be followed by options that override the shared settings for it alone,
+// e.g.: `file:output-file=take2.wav:bits-per-sample=24` (or `file:take2.wav:bits-per-sample=24`)
+func parseDeviceSpecs(settings deviceCommon.Settings) ([]deviceSpec, error) {
+	var specs []deviceSpec
+	for _, entry := range strings.Split(settings.Name, teeSeparator) {
+		parts := strings.Split(entry, teeOptionSeparator)
+		spec := deviceSpec{
+			settings: settings,
+		}
+		spec.settings.Name = strings.TrimSpace(parts[0])
+
+		// a part without an `=` continues the previous value (e.g.: a filepath like `C:\take2.wav`),
+		// unless it comes first, when it's the value of the device's default option
+		var options []string
+		for _, part := range parts[1:] {
+			if !strings.Contains(part, "=") {
+				if len(options) > 0 {
+					options[len(options)-1] += teeOptionSeparator + part
+					continue
+				}
+				if key := Map[spec.settings.Name].defaultOption; key != "" {
+					part = key + "=" + part
+				}
+			}
+			options = append(options, part)
+		}
+
+		for _, option := range options {
+			key, value, _ := strings.Cut(option, "=")
+			if err := spec.setOption(strings.TrimSpace(key), value); err != nil {
+				return nil, fmt.Errorf("device %q: %w", spec.settings.Name, err)
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+func (s *deviceSpec) setOption(key, value string) error {
+	switch key {
+	case "output-file":
+		s.settings.Filepath = value
+	case "bits-per-sample":
+		bits, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid bits per sample %q", value)
+		}
+		s.settings.BitsPerSample = bits
+	case "sample-format":
+		s.settings.SampleFormat = value
+	case "sink":
+		s.settings.Sink = value
+	default:
+		return fmt.Errorf("unknown option %q", key)
+	}
+	return nil
+}
+
+func newTeeDevice(specs []deviceSpec) (Device, error) {
+	// only one of the devices reports the rows it outputs, so they don't get reported more than once
+	// - the sound card's (or the stream's), if there is one, as that's what is being listened to
+	reporter := 0
+	for i, spec := range specs {
+		if teeKindPrecedence[Map[spec.settings.Name].Kind] > teeKindPrecedence[Map[specs[reporter].settings.Name].Kind] {
+			reporter = i
+		}
+	}
+
+	d := teeDevice{}
+	for i, spec := range specs {
+		if i != reporter {
+			spec.settings.OnRowOutput = nil
+		}
+		dev, err := CreateOutputDevice(spec.settings)
+		if err != nil {
+			_ = d.Close()
+			return nil, err
+		}
+		d.devices = append(d.devices, dev)
+	}
+	return &d, nil
+}
+
+// GetKind returns the kind of the most interactive device of the tee, so that
+// the song keeps looping when a sound card (or a stream) is being listened to alongside a file
+func (d teeDevice) GetKind() deviceCommon.Kind {
+	kind := deviceCommon.KindNone
+	for _, dev := range d.devices {
+		if k := GetKind(dev); teeKindPrecedence[k] > teeKindPrecedence[kind] {
+			kind = k
+		}
+	}
+	return kind
+}
+
+// Name returns the device name
+func (d teeDevice) Name() string {
+	names := make([]string, len(d.devices))
+	for i, dev := range d.devices {
+		names[i] = dev.Name()
+	}
+	return strings.Join(names, teeSeparator)
+}
+
+// Play starts the tee output device playing
+func (d *teeDevice) Play(in <-chan *output.PremixData) error {
+	return d.PlayWithCtx(context.Background(), in)
+}
+
+// PlayWithCtx starts the tee output device playing
+func (d *teeDevice) PlayWithCtx(ctx context.Context, in <-chan *output.PremixData) error {
+	myCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	outs := make([]chan *output.PremixData, len(d.devices))
+	done := make([]chan struct{}, len(d.devices))
+	for i, dev := range d.devices {
+		outs[i] = make(chan *output.PremixData, teeBufferSize)
+		done[i] = make(chan struct{})
+		wg.Add(1)
+		go func(dev Device, in <-chan *output.PremixData, done chan<- struct{}) {
+			defer wg.Done()
+			defer close(done)
+			if err := dev.PlayWithCtx(myCtx, in); err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+				// one device failing stops them all
+				cancel()
+			}
+		}(dev, outs[i], done[i])
+	}
+
+feed:
+	for {
+		select {
+		case <-myCtx.Done():
+			break feed
+		case row, ok := <-in:
+			if !ok {
+				break feed
+			}
+			// the devices only read the row, so they can all share it
+			for i, out := range outs {
+				select {
+				case out <- row:
+				case <-done[i]:
+					// the device has stopped on its own, so it doesn't want any more
+				case <-myCtx.Done():
+					break feed
+				}
+			}
+		}
+	}
+
+	for _, out := range outs {
+		close(out)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// CanPause returns whether all the devices of the tee can be paused
+func (d *teeDevice) CanPause() bool {
+	for _, dev := range d.devices {
+		if !CanPause(dev) {
+			return false
+		}
+	}
+	return true
+}
+
+// Pause pauses the devices of the tee that support it
+func (d *teeDevice) Pause() error {
+	var errs []error
+	for _, dev := range d.devices {
+		errs = append(errs, Pause(dev))
+	}
+	return errors.Join(errs...)
+}
+
+// Resume resumes the devices of the tee that support it
+func (d *teeDevice) Resume() error {
+	var errs []error
+	for _, dev := range d.devices {
+		errs = append(errs, Resume(dev))
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the devices of the tee
+func (d *teeDevice) Close() error {
+	var errs []error
+	for _, dev := range d.devices {
+		errs = append(errs, dev.Close())
+	}
+	d.devices = nil
+	return errors.Join(errs...)
+}
@@ -10,7 +10,6 @@ import (
 
 	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
 	"github.com/gotracker/playback/mixing"
-	"github.com/gotracker/playback/mixing/sampling"
 	"github.com/gotracker/playback/output"
 	winmm "github.com/heucuva/go-winmm"
 )
@@ -19,9 +18,9 @@ const winmmName = "winmm"
 
 type winmmDevice struct {
 	device
-	mix     mixing.Mixer
-	sampFmt sampling.Format
-	waveout *winmm.WaveOut
+	flattener *deviceCommon.Flattener
+	sampFmt   deviceCommon.SampleFormat
+	waveout   *winmm.WaveOut
 }
 
 func (winmmDevice) GetKind() deviceCommon.Kind {
@@ -34,23 +33,25 @@ func (winmmDevice) Name() string {
 }
 
 func newWinMMDevice(settings deviceCommon.Settings) (Device, error) {
+	flattener, err := deviceCommon.NewFlattener(settings)
+	if err != nil {
+		return nil, err
+	}
+
 	d := winmmDevice{
 		device: device{
 			onRowOutput: settings.OnRowOutput,
 		},
-		mix: mixing.Mixer{
-			Channels: settings.Channels,
-		},
+		flattener: flattener,
 	}
 
 	switch settings.BitsPerSample {
 	case 8:
-		d.sampFmt = sampling.Format8BitUnsigned
+		d.sampFmt = deviceCommon.SampleFormatU8
 	case 16:
-		d.sampFmt = sampling.Format16BitLESigned
+		d.sampFmt = deviceCommon.SampleFormatS16LE
 	}
 
-	var err error
 	d.waveout, err = winmm.New(settings.Channels, settings.SamplesPerSecond, settings.BitsPerSample)
 	if err != nil {
 		return nil, err
@@ -73,7 +74,7 @@ func (d *winmmDevice) PlayWithCtx(ctx context.Context, in <-chan *output.PremixD
 		Row  *output.PremixData
 	}
 
-	panmixer := mixing.GetPanMixer(d.mix.Channels)
+	panmixer := mixing.GetPanMixer(d.flattener.Channels())
 	if panmixer == nil {
 		return errors.New("invalid pan mixer - check channel count")
 	}
@@ -93,7 +94,11 @@ func (d *winmmDevice) PlayWithCtx(ctx context.Context, in <-chan *output.PremixD
 				if !ok {
 					return
 				}
-				mixedData := d.mix.Flatten(row.SamplesLen, row.Data, row.MixerVolume, d.sampFmt)
+				if row.SamplesLen == 0 {
+					// nothing to play (e.g.: the details of a song about to start)
+					continue
+				}
+				mixedData := d.flattener.Flatten(row, d.sampFmt)
 				rowWave := RowWave{
 					Wave: d.waveout.Write(mixedData),
 					Row:  row,
@@ -134,5 +139,11 @@ func init() {
 	Map[winmmName] = deviceDetails{
 		create: newWinMMDevice,
 		Kind:   deviceCommon.KindSoundCard,
+		capabilities: fixedCapabilities(deviceCommon.Capabilities{
+			MinSampleRate: 100,
+			MaxSampleRate: 200000,
+			BitsPerSample: []int{8, 16},
+			Channels:      deviceCommon.MixerChannels,
+		}),
 	}
 }
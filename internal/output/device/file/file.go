@@ -8,7 +8,8 @@ import (
 )
 
 var (
-	fileDeviceMap = make(map[string]FileFactory)
+	fileDeviceMap       = make(map[string]FileFactory)
+	fileCapabilitiesMap = make(map[string]deviceCommon.Capabilities)
 )
 
 type FileFactory func(settings deviceCommon.Settings) (File, error)
@@ -25,3 +26,27 @@ func GetFileDevice(extension string) (FileFactory, bool) {
 	factory, ok := fileDeviceMap[extension]
 	return factory, ok
 }
+
+// GetFileCapabilities returns the formats the file device for `extension` can write,
+// or the formats any of them can write when there isn't one for it
+func GetFileCapabilities(extension string) deviceCommon.Capabilities {
+	if caps, ok := fileCapabilitiesMap[extension]; ok {
+		return caps
+	}
+	return unionCapabilities(fileCapabilitiesMap)
+}
+
+func unionCapabilities(m map[string]deviceCommon.Capabilities) deviceCommon.Capabilities {
+	var (
+		union deviceCommon.Capabilities
+		first = true
+	)
+	for _, caps := range m {
+		if first {
+			union, first = caps, false
+			continue
+		}
+		union = union.Union(caps)
+	}
+	return union
+}
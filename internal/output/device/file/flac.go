@@ -5,36 +5,102 @@ package file
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/md5"
 	"errors"
+	"hash"
+	"io"
 	"os"
 
 	"github.com/mewkiz/flac"
 	"github.com/mewkiz/flac/frame"
 	"github.com/mewkiz/flac/meta"
 
+	"github.com/gotracker/gotracker/internal/loudness"
 	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
 	"github.com/gotracker/playback/mixing"
 	"github.com/gotracker/playback/output"
 )
 
+const (
+	flacBlockSize = 4096
+	// the stream info block comes right after the `fLaC` signature and the block's header
+	flacStreamInfoPos  = 4 + 4
+	flacStreamInfoSize = 34
+)
+
+var (
+	// the sample sizes and rates that the frame headers can describe
+	flacCapabilities = deviceCommon.Capabilities{
+		MinSampleRate: 1,
+		MaxSampleRate: 655350,
+		BitsPerSample: []int{8, 12, 16, 20, 24},
+		Channels:      deviceCommon.MixerChannels,
+	}
+)
+
 type fileFlac struct {
-	mix              mixing.Mixer
+	flattener        *deviceCommon.Flattener
 	samplesPerSecond int
 	bitsPerSample    int
+	channels         frame.Channels
+
+	f   *os.File
+	w   *bufio.Writer
+	cw  countingWriter
+	enc *flac.Encoder
 
-	f *os.File
-	w *bufio.Writer
+	info     meta.StreamInfo
+	md5sum   hash.Hash
+	pending  [][]int32
+	metadata flacMetadata
+}
+
+// countingWriter counts the bytes written through it, so that the frame sizes can be found
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
 func newFileFlacDevice(settings deviceCommon.Settings) (File, error) {
+	flattener, err := deviceCommon.NewFlattener(settings)
+	if err != nil {
+		return nil, err
+	}
+
 	fd := fileFlac{
-		mix: mixing.Mixer{
-			Channels: settings.Channels,
-		},
+		flattener:        flattener,
 		samplesPerSecond: settings.SamplesPerSecond,
 		bitsPerSample:    settings.BitsPerSample,
+		md5sum:           md5.New(),
+		pending:          make([][]int32, settings.Channels),
+		metadata: flacMetadata{
+			meter: loudness.NewMeter(settings.Channels, settings.SamplesPerSecond),
+		},
 	}
+
+	channels, err := getFlacChannels(settings.Channels)
+	if err != nil {
+		return nil, err
+	}
+	fd.channels = channels
+
+	var blocks []*meta.Block
+	if settings.Picture != "" {
+		pic, err := newFlacPicture(settings.Picture)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, pic)
+	}
+
 	f, err := os.OpenFile(settings.Filepath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
 	if err != nil {
 		return nil, err
@@ -45,48 +111,49 @@ func newFileFlacDevice(settings deviceCommon.Settings) (File, error) {
 	}
 
 	fd.f = f
+	fd.w = bufio.NewWriter(f)
+	fd.cw.w = fd.w
+
+	// the rest of the stream info gets filled in when the device is closed
+	fd.info = meta.StreamInfo{
+		BlockSizeMin:  flacBlockSize,
+		BlockSizeMax:  flacBlockSize,
+		SampleRate:    uint32(fd.samplesPerSecond),
+		NChannels:     uint8(settings.Channels),
+		BitsPerSample: uint8(fd.bitsPerSample),
+	}
+	info := fd.info
+	// the reserved space comes last, so that the pictures are where they'll stay
+	fd.metadata.pos = flacStreamInfoPos + flacStreamInfoSize
+	for _, b := range blocks {
+		fd.metadata.pos += flacBlockHeaderSize + b.Length
+	}
+	blocks = append(blocks, newFlacReserve())
+	enc, err := flac.NewEncoder(&fd.cw, &info, blocks...)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	fd.metadata.frameStart = fd.cw.n
+	// the subframes get analyzed as they are built
+	enc.EnablePredictionAnalysis(false)
+	fd.enc = enc
 
 	return &fd, nil
 }
 
-// Play starts the wave output device playing
+// Play starts the flac output device playing
 func (d *fileFlac) Play(in <-chan *output.PremixData, onWrittenCallback WrittenCallback) error {
 	return d.PlayWithCtx(context.Background(), in, onWrittenCallback)
 }
 
-// PlayWithCtx starts the wave output device playing
+// PlayWithCtx starts the flac output device playing
 func (d *fileFlac) PlayWithCtx(ctx context.Context, in <-chan *output.PremixData, onWrittenCallback WrittenCallback) error {
-	w := bufio.NewWriter(d.f)
-	d.w = w
-	// Encode FLAC stream.
-	si := &meta.StreamInfo{
-		BlockSizeMin:  16,
-		BlockSizeMax:  65535,
-		SampleRate:    uint32(d.samplesPerSecond),
-		NChannels:     uint8(d.mix.Channels),
-		BitsPerSample: uint8(d.bitsPerSample),
-	}
-	enc, err := flac.NewEncoder(w, si)
-	if err != nil {
-		return err
-	}
-	defer enc.Close()
-
-	panmixer := mixing.GetPanMixer(d.mix.Channels)
+	panmixer := mixing.GetPanMixer(d.flattener.Channels())
 	if panmixer == nil {
 		return errors.New("invalid pan mixer - check channel count")
 	}
 
-	var channels frame.Channels
-	switch d.mix.Channels {
-	case 1:
-		channels = frame.ChannelsMono
-	case 2:
-		channels = frame.ChannelsLR
-	case 4:
-		channels = frame.ChannelsLRLsRs
-	}
-
 	myCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -98,44 +165,17 @@ func (d *fileFlac) PlayWithCtx(ctx context.Context, in <-chan *output.PremixData
 			if !ok {
 				return nil
 			}
-			mixedData := d.mix.FlattenToInts(panmixer.NumChannels(), row.SamplesLen, d.bitsPerSample, row.Data, row.MixerVolume)
-			subframes := make([]*frame.Subframe, d.mix.Channels)
-			for i := range subframes {
-				subframe := &frame.Subframe{
-					SubHeader: frame.SubHeader{
-						Pred: frame.PredVerbatim,
-					},
-					Samples:  mixedData[i],
-					NSamples: row.SamplesLen,
-				}
-				subframes[i] = subframe
+			d.metadata.update(deviceCommon.Userdata(row))
+			mixedData := d.flattener.FlattenToInts(row, d.bitsPerSample)
+			d.metadata.meter.Add(mixedData, d.bitsPerSample)
+			for c := range d.pending {
+				d.pending[c] = append(d.pending[c], mixedData[c]...)
 			}
-			for _, subframe := range subframes {
-				sample := subframe.Samples[0]
-				constant := true
-				for _, s := range subframe.Samples[1:] {
-					if sample != s {
-						constant = false
-					}
-				}
-				if constant {
-					subframe.SubHeader.Pred = frame.PredConstant
+			for len(d.pending[0]) >= flacBlockSize {
+				if err := d.writeFrame(flacBlockSize); err != nil {
+					return err
 				}
 			}
-
-			fr := &frame.Frame{
-				Header: frame.Header{
-					HasFixedBlockSize: false,
-					BlockSize:         uint16(row.SamplesLen),
-					SampleRate:        uint32(d.samplesPerSecond),
-					Channels:          channels,
-					BitsPerSample:     uint8(d.bitsPerSample),
-				},
-				Subframes: subframes,
-			}
-			if err := enc.WriteFrame(fr); err != nil {
-				return err
-			}
 			if onWrittenCallback != nil {
 				onWrittenCallback(row)
 			}
@@ -143,15 +183,112 @@ func (d *fileFlac) PlayWithCtx(ctx context.Context, in <-chan *output.PremixData
 	}
 }
 
-// Close closes the wave output device
+// getFlacChannels returns the channel assignment of a frame with `channels` channels
+func getFlacChannels(channels int) (frame.Channels, error) {
+	switch channels {
+	case 1:
+		return frame.ChannelsMono, nil
+	case 2:
+		return frame.ChannelsLR, nil
+	case 4:
+		return frame.ChannelsLRLsRs, nil
+	default:
+		return 0, errors.New("unsupported channel count")
+	}
+}
+
+// newFlacFrame returns a frame holding the first `n` samples of each channel in `samples`
+func newFlacFrame(samples [][]int32, n int, samplesPerSecond int, bitsPerSample int, channels frame.Channels) *frame.Frame {
+	subframes := make([]*frame.Subframe, len(samples))
+	for c := range subframes {
+		subframes[c] = newFlacSubframe(samples[c][:n], uint(bitsPerSample))
+	}
+
+	return &frame.Frame{
+		Header: frame.Header{
+			HasFixedBlockSize: true,
+			BlockSize:         uint16(n),
+			SampleRate:        uint32(samplesPerSecond),
+			Channels:          channels,
+			BitsPerSample:     uint8(bitsPerSample),
+		},
+		Subframes: subframes,
+	}
+}
+
+// writeFrame encodes the first `n` pending samples of each channel into a frame
+func (d *fileFlac) writeFrame(n int) error {
+	fr := newFlacFrame(d.pending, n, d.samplesPerSecond, d.bitsPerSample, d.channels)
+	fr.Hash(d.md5sum)
+
+	start := d.cw.n
+	d.metadata.addSeekPoint(d.info.NSamples, n, start, d.samplesPerSecond)
+	if err := d.enc.WriteFrame(fr); err != nil {
+		return err
+	}
+	size := uint32(d.cw.n - start)
+	if d.info.FrameSizeMin == 0 || size < d.info.FrameSizeMin {
+		d.info.FrameSizeMin = size
+	}
+	d.info.FrameSizeMax = max(d.info.FrameSizeMax, size)
+	d.info.NSamples += uint64(n)
+
+	for c := range d.pending {
+		d.pending[c] = append(d.pending[c][:0], d.pending[c][n:]...)
+	}
+	return nil
+}
+
+// writeStreamInfo fills in the stream info block with the details of the finished stream,
+// and the reserved space with the rest of the metadata
+func (d *fileFlac) writeStreamInfo() error {
+	if d.info.NSamples < flacBlockSize {
+		// the last block doesn't count towards the block sizes, unless it's the only one
+		d.info.BlockSizeMin = uint16(d.info.NSamples)
+		d.info.BlockSizeMax = uint16(d.info.NSamples)
+	}
+	copy(d.info.MD5sum[:], d.md5sum.Sum(nil))
+
+	buf := &bytes.Buffer{}
+	if _, err := flac.NewEncoder(buf, &d.info); err != nil {
+		return err
+	}
+	if _, err := d.f.WriteAt(buf.Bytes()[flacStreamInfoPos:], flacStreamInfoPos); err != nil {
+		return err
+	}
+
+	md, err := d.metadata.encode(&d.info)
+	if err != nil {
+		return err
+	}
+	if _, err := d.f.WriteAt(md, d.metadata.pos); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Close closes the flac output device
 func (d *fileFlac) Close() error {
+	if n := len(d.pending[0]); n > 0 {
+		if err := d.writeFrame(n); err != nil {
+			return err
+		}
+	}
+	if err := d.enc.Close(); err != nil {
+		return err
+	}
 	if err := d.w.Flush(); err != nil {
 		return err
 	}
 	d.w = nil
+
+	if err := d.writeStreamInfo(); err != nil {
+		return err
+	}
 	return d.f.Close()
 }
 
 func init() {
 	fileDeviceMap[".flac"] = newFileFlacDevice
+	fileCapabilitiesMap[".flac"] = flacCapabilities
 }
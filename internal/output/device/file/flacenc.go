@@ -0,0 +1,300 @@
+//go:build flac
+// +build flac
+
+package file
+
+import (
+	"math"
+
+	"github.com/mewkiz/flac/frame"
+)
+
+const (
+	flacMaxLPCOrder       = 12
+	flacLPCPrecision      = 12 // bits per coefficient, as suits blocks of about 4096 samples
+	flacMaxPartitionOrder = 8
+	flacMaxRice1Param     = 14 // 15 is the escape code
+	flacMaxRice2Param     = 30 // 31 is the escape code
+)
+
+// newFlacSubframe returns the subframe which encodes `samples` in the fewest bits,
+// out of the constant, verbatim, fixed and LPC prediction methods
+func newFlacSubframe(samples []int32, bps uint) *frame.Subframe {
+	sf := &frame.Subframe{
+		SubHeader: frame.SubHeader{
+			Pred: frame.PredVerbatim,
+		},
+		Samples:  samples,
+		NSamples: len(samples),
+	}
+
+	constant := true
+	for _, s := range samples[1:] {
+		if s != samples[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		sf.Pred = frame.PredConstant
+		return sf
+	}
+
+	// every subframe starts with a 6-bit type and the (empty) wasted bits flag
+	const headerBits = 1 + 6 + 1
+	best := headerBits + len(samples)*int(bps)
+
+	for order := 0; order <= 4 && order < len(samples); order++ {
+		residuals, ok := flacResiduals(samples, frame.FixedCoeffs[order], 0)
+		if !ok {
+			continue
+		}
+		method, rice, bits := flacRiceParams(residuals, order, len(samples))
+		bits += headerBits + order*int(bps)
+		if bits < best {
+			best = bits
+			sf.SubHeader = frame.SubHeader{
+				Pred:                 frame.PredFixed,
+				Order:                order,
+				ResidualCodingMethod: method,
+				RiceSubframe:         rice,
+			}
+		}
+	}
+
+	for order, lpc := range flacLPCCoeffs(samples, flacMaxLPCOrder) {
+		order++ // orders start at 1
+		coeffs, shift, ok := flacQuantizeCoeffs(lpc, flacLPCPrecision)
+		if !ok {
+			continue
+		}
+		residuals, ok := flacResiduals(samples, coeffs, shift)
+		if !ok {
+			continue
+		}
+		method, rice, bits := flacRiceParams(residuals, order, len(samples))
+		bits += headerBits + order*int(bps) + 4 + 5 + order*flacLPCPrecision
+		if bits < best {
+			best = bits
+			sf.SubHeader = frame.SubHeader{
+				Pred:                 frame.PredFIR,
+				Order:                order,
+				ResidualCodingMethod: method,
+				CoeffPrec:            flacLPCPrecision,
+				CoeffShift:           shift,
+				Coeffs:               coeffs,
+				RiceSubframe:         rice,
+			}
+		}
+	}
+
+	return sf
+}
+
+// flacResiduals returns the difference between the samples and their prediction from the previous
+// `len(coeffs)` samples, or false if the prediction or the differences don't fit in 32 bits
+func flacResiduals(samples []int32, coeffs []int32, shift int32) ([]int32, bool) {
+	order := len(coeffs)
+	if order >= len(samples) {
+		return nil, false
+	}
+	residuals := make([]int32, 0, len(samples)-order)
+	for i := order; i < len(samples); i++ {
+		var sum int64
+		for j, c := range coeffs {
+			sum += int64(c) * int64(samples[i-j-1])
+		}
+		predicted := sum >> uint(shift)
+		residual := int64(samples[i]) - predicted
+		if predicted != int64(int32(predicted)) || residual != int64(int32(residual)) {
+			return nil, false
+		}
+		residuals = append(residuals, int32(residual))
+	}
+	return residuals, true
+}
+
+// flacLPCCoeffs returns the linear prediction coefficients of every order up to `maxOrder`
+// (from 1, but possibly fewer when a lower order already predicts the samples perfectly)
+func flacLPCCoeffs(samples []int32, maxOrder int) [][]float64 {
+	maxOrder = min(maxOrder, len(samples)-1)
+	if maxOrder <= 0 {
+		return nil
+	}
+
+	// tukey(0.5) window
+	n := len(samples)
+	windowed := make([]float64, n)
+	np := n / 4
+	for i, s := range samples {
+		w := 1.0
+		switch {
+		case i < np:
+			w = 0.5 - 0.5*math.Cos(math.Pi*float64(i)/float64(np))
+		case i >= n-np:
+			w = 0.5 - 0.5*math.Cos(math.Pi*float64(n-1-i)/float64(np))
+		}
+		windowed[i] = float64(s) * w
+	}
+
+	autoc := make([]float64, maxOrder+1)
+	for lag := range autoc {
+		var sum float64
+		for i := lag; i < n; i++ {
+			sum += windowed[i] * windowed[i-lag]
+		}
+		autoc[lag] = sum
+	}
+	if autoc[0] == 0 {
+		return nil
+	}
+
+	// Levinson-Durbin recursion
+	var lpcs [][]float64
+	lpc := make([]float64, maxOrder)
+	err := autoc[0]
+	for i := 0; i < maxOrder; i++ {
+		r := -autoc[i+1]
+		for j := 0; j < i; j++ {
+			r -= lpc[j] * autoc[i-j]
+		}
+		r /= err
+
+		lpc[i] = r
+		for j := 0; j < i/2; j++ {
+			tmp := lpc[j]
+			lpc[j] += r * lpc[i-1-j]
+			lpc[i-1-j] += r * tmp
+		}
+		if i%2 != 0 {
+			lpc[i/2] += lpc[i/2] * r
+		}
+
+		// the filter coefficients are the negated prediction coefficients
+		coeffs := make([]float64, i+1)
+		for j := range coeffs {
+			coeffs[j] = -lpc[j]
+		}
+		lpcs = append(lpcs, coeffs)
+
+		err *= 1 - r*r
+		if err <= 0 {
+			break
+		}
+	}
+	return lpcs
+}
+
+// flacQuantizeCoeffs turns the coefficients into integers of `precision` bits, which are
+// then scaled down by `shift` bits
+func flacQuantizeCoeffs(lpc []float64, precision int) ([]int32, int32, bool) {
+	var cmax float64
+	for _, c := range lpc {
+		cmax = max(cmax, math.Abs(c))
+	}
+	if cmax <= 0 {
+		return nil, 0, false
+	}
+
+	qmax := int64(1)<<(precision-1) - 1
+	qmin := -qmax - 1
+
+	_, log2cmax := math.Frexp(cmax)
+	shift := precision - log2cmax - 1
+	if shift < 0 {
+		// the subframe format allows for negative shifts, but the encoder doesn't
+		return nil, 0, false
+	}
+	shift = min(shift, 15)
+
+	coeffs := make([]int32, len(lpc))
+	// carry the rounding error over to the next coefficient
+	var errAcc float64
+	for i, c := range lpc {
+		errAcc += c * float64(int64(1)<<shift)
+		q := min(max(int64(math.Round(errAcc)), qmin), qmax)
+		errAcc -= float64(q)
+		coeffs[i] = int32(q)
+	}
+	return coeffs, int32(shift), true
+}
+
+// flacRiceParams returns the rice coding of the residuals of a subframe of `blockSize` samples
+// predicted at `order`, along with the (estimated) number of bits it takes
+func flacRiceParams(residuals []int32, order int, blockSize int) (frame.ResidualCodingMethod, *frame.RiceSubframe, int) {
+	// the partitions split the block evenly, with the first partition missing the warm-up samples
+	maxPartOrder := 0
+	for p := 1; p <= flacMaxPartitionOrder; p++ {
+		if blockSize%(1<<p) != 0 || blockSize>>p <= order {
+			break
+		}
+		maxPartOrder = p
+	}
+
+	// the sums (and counts) of the folded residuals of each partition of the highest order
+	nparts := 1 << maxPartOrder
+	sums := make([]uint64, nparts)
+	counts := make([]int, nparts)
+	partLen := blockSize >> maxPartOrder
+	for i, r := range residuals {
+		part := (i + order) / partLen
+		sums[part] += uint64(uint32(r<<1) ^ uint32(r>>31)) // zigzag
+		counts[part]++
+	}
+
+	var (
+		bestMethod = frame.ResidualCodingMethodRice1
+		bestRice   *frame.RiceSubframe
+		bestBits   = math.MaxInt
+	)
+	for p := maxPartOrder; p >= 0; p-- {
+		if p < maxPartOrder {
+			// merge pairs of partitions into those of the next order down
+			for i := 0; i < len(sums)/2; i++ {
+				sums[i] = sums[2*i] + sums[2*i+1]
+				counts[i] = counts[2*i] + counts[2*i+1]
+			}
+			sums = sums[:len(sums)/2]
+			counts = counts[:len(counts)/2]
+		}
+
+		for _, method := range []frame.ResidualCodingMethod{frame.ResidualCodingMethodRice1, frame.ResidualCodingMethodRice2} {
+			paramSize, maxParam := 4, uint(flacMaxRice1Param)
+			if method == frame.ResidualCodingMethodRice2 {
+				paramSize, maxParam = 5, flacMaxRice2Param
+			}
+
+			// residual coding method and partition order
+			bits := 2 + 4
+			partitions := make([]frame.RicePartition, len(sums))
+			for i, sum := range sums {
+				param, pbits := flacBestRiceParam(sum, counts[i], maxParam)
+				partitions[i].Param = param
+				bits += paramSize + pbits
+			}
+			if bits < bestBits {
+				bestBits = bits
+				bestMethod = method
+				bestRice = &frame.RiceSubframe{
+					PartOrder:  p,
+					Partitions: partitions,
+				}
+			}
+		}
+	}
+	return bestMethod, bestRice, bestBits
+}
+
+// flacBestRiceParam returns the rice parameter that best codes `n` folded residuals adding up to `sum`,
+// along with the (estimated) number of bits they take
+func flacBestRiceParam(sum uint64, n int, maxParam uint) (uint, int) {
+	bestParam, bestBits := uint(0), math.MaxInt
+	for k := uint(0); k <= maxParam; k++ {
+		// each residual takes a stop bit and k low bits, with the rest of it in unary
+		bits := uint64(n)*uint64(k+1) + sum>>k
+		if bits < uint64(bestBits) {
+			bestParam, bestBits = k, int(bits)
+		}
+	}
+	return bestParam, bestBits
+}
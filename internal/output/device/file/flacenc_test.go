@@ -0,0 +1,88 @@
+//go:build flac
+// +build flac
+
+package file
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+func TestFlacSubframeRoundTrip(t *testing.T) {
+	const bps = 16
+	blocks := map[string][]int32{
+		"silence": make([]int32, flacBlockSize),
+		"short":   {1, -2, 3},
+	}
+	tone := make([]int32, flacBlockSize)
+	noisy := make([]int32, 1000)
+	for i := range tone {
+		tone[i] = int32(20000 * math.Sin(float64(i)*0.05))
+	}
+	for i := range noisy {
+		noisy[i] = int32((i*7919)%65536 - 32768)
+	}
+	blocks["tone"] = tone
+	blocks["noisy"] = noisy
+
+	for name, samples := range blocks {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			enc, err := flac.NewEncoder(buf, &meta.StreamInfo{
+				BlockSizeMin:  16,
+				BlockSizeMax:  flacBlockSize,
+				SampleRate:    44100,
+				NChannels:     1,
+				BitsPerSample: bps,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			enc.EnablePredictionAnalysis(false)
+
+			sf := newFlacSubframe(append([]int32(nil), samples...), bps)
+			if err := enc.WriteFrame(&frame.Frame{
+				Header: frame.Header{
+					HasFixedBlockSize: true,
+					BlockSize:         uint16(len(samples)),
+					SampleRate:        44100,
+					Channels:          frame.ChannelsMono,
+					BitsPerSample:     bps,
+				},
+				Subframes: []*frame.Subframe{sf},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if name == "tone" && sf.Pred != frame.PredFIR {
+				t.Errorf("expected a tone to be predicted with LPC, got %v", sf.Pred)
+			}
+
+			s, err := flac.New(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fr, err := s.ParseNext()
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := fr.Subframes[0].Samples
+			if len(decoded) != len(samples) {
+				t.Fatalf("expected %d samples, got %d", len(samples), len(decoded))
+			}
+			for i := range samples {
+				if decoded[i] != samples[i] {
+					t.Fatalf("sample %d: expected %d, got %d", i, samples[i], decoded[i])
+				}
+			}
+			if _, err := s.ParseNext(); err != io.EOF {
+				t.Fatalf("expected the end of the stream, got %v", err)
+			}
+		})
+	}
+}
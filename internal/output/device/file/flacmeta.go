@@ -0,0 +1,237 @@
+//go:build flac
+// +build flac
+
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/meta"
+
+	"github.com/gotracker/gotracker/internal/loudness"
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+)
+
+const (
+	// the song details aren't known until playback starts, so room is set aside for the
+	// seek table and the comments, which get written over it when the device is closed
+	flacMetadataReserve = 64 * 1024
+	// how far apart the seek points are placed (in seconds)
+	flacSeekInterval = 10
+	// how loud ReplayGain 2.0 expects a track to be (in LUFS)
+	flacReplayGainReference = -18.0
+
+	flacBlockHeaderSize   = 4
+	flacPictureFrontCover = 3
+)
+
+// flacMetadata collects the metadata of a rendered flac file
+type flacMetadata struct {
+	// where the reserved space starts (including its block header)
+	pos int64
+	// where the first frame starts
+	frameStart int64
+
+	song       *deviceCommon.SongInfo
+	seekPoints []meta.SeekPoint
+	nextSeek   uint64
+	meter      *loudness.Meter
+}
+
+// newFlacPicture loads the image at `path` as a front cover picture block
+func newFlacPicture(path string) (*meta.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not read picture %q: %w", path, err)
+	}
+
+	pic := &meta.Picture{
+		Type:   flacPictureFrontCover,
+		MIME:   http.DetectContentType(data),
+		Width:  uint32(cfg.Width),
+		Height: uint32(cfg.Height),
+		Depth:  24,
+		Data:   data,
+	}
+	switch m := cfg.ColorModel.(type) {
+	case color.Palette:
+		pic.Depth = 8
+		pic.NPalColors = uint32(len(m))
+	default:
+		switch m {
+		case color.GrayModel:
+			pic.Depth = 8
+		case color.Gray16Model:
+			pic.Depth = 16
+		case color.RGBAModel, color.NRGBAModel, color.CMYKModel:
+			pic.Depth = 32
+		case color.RGBA64Model, color.NRGBA64Model:
+			pic.Depth = 64
+		}
+	}
+
+	return &meta.Block{
+		Header: meta.Header{
+			Type:   meta.TypePicture,
+			Length: int64(32 + len(pic.MIME) + len(pic.Desc) + len(pic.Data)),
+		},
+		Body: pic,
+	}, nil
+}
+
+// newFlacReserve returns the padding block holding the space set aside for the metadata
+func newFlacReserve() *meta.Block {
+	return &meta.Block{
+		Header: meta.Header{
+			Type:   meta.TypePadding,
+			Length: flacMetadataReserve,
+		},
+	}
+}
+
+// update notes the song about to be output
+func (m *flacMetadata) update(userdata any) {
+	if song, ok := userdata.(*deviceCommon.SongInfo); ok && m.song == nil {
+		m.song = song
+	}
+}
+
+// addSeekPoint notes the frame of `nSamples` samples starting at sample `sampleNum`,
+// which was written at `pos`, if it's far enough along to deserve a seek point
+func (m *flacMetadata) addSeekPoint(sampleNum uint64, nSamples int, pos int64, sampleRate int) {
+	if sampleNum < m.nextSeek {
+		return
+	}
+	m.seekPoints = append(m.seekPoints, meta.SeekPoint{
+		SampleNum: sampleNum,
+		Offset:    uint64(pos - m.frameStart),
+		NSamples:  uint16(nSamples),
+	})
+	m.nextSeek = sampleNum + uint64(flacSeekInterval*sampleRate)
+}
+
+// comments returns the vorbis comment block
+func (m *flacMetadata) comments() *meta.Block {
+	vc := &meta.VorbisComment{
+		Vendor: softwareName,
+		Tags: [][2]string{
+			{"ENCODER", softwareName},
+		},
+	}
+	if m.song != nil {
+		if m.song.Title != "" {
+			vc.Tags = append(vc.Tags, [2]string{"TITLE", m.song.Title})
+		}
+		if m.song.Artist != "" {
+			vc.Tags = append(vc.Tags, [2]string{"ARTIST", m.song.Artist})
+		}
+		if m.song.Filepath != "" {
+			vc.Tags = append(vc.Tags, [2]string{"ORIGINALFILENAME", filepath.Base(m.song.Filepath)})
+		}
+		if m.song.Format != "" {
+			vc.Tags = append(vc.Tags, [2]string{"SOURCEMEDIA", m.song.Format})
+		}
+	}
+	if m.meter != nil {
+		if lufs, ok := m.meter.Integrated(); ok {
+			vc.Tags = append(vc.Tags,
+				[2]string{"REPLAYGAIN_TRACK_GAIN", fmt.Sprintf("%+.2f dB", flacReplayGainReference-lufs)},
+				[2]string{"REPLAYGAIN_TRACK_PEAK", fmt.Sprintf("%.6f", m.meter.Peak())},
+			)
+		}
+	}
+
+	length := 4 + len(vc.Vendor) + 4
+	for _, tag := range vc.Tags {
+		length += 4 + len(tag[0]) + 1 + len(tag[1])
+	}
+	return &meta.Block{
+		Header: meta.Header{
+			Type:   meta.TypeVorbisComment,
+			Length: int64(length),
+		},
+		Body: vc,
+	}
+}
+
+// seekTable returns the seek table block holding `points`
+func (m *flacMetadata) seekTable(points []meta.SeekPoint) *meta.Block {
+	return &meta.Block{
+		Header: meta.Header{
+			Type:   meta.TypeSeekTable,
+			Length: int64(18 * len(points)),
+		},
+		Body: &meta.SeekTable{
+			Points: points,
+		},
+	}
+}
+
+// encode returns the metadata blocks, sized to fill the reserved space exactly
+func (m *flacMetadata) encode(info *meta.StreamInfo) ([]byte, error) {
+	const space = flacBlockHeaderSize + flacMetadataReserve
+	// the blocks come out after the signature and the stream info
+	const skip = flacStreamInfoPos + flacStreamInfoSize
+
+	comments := m.comments()
+	points := m.seekPoints
+	for {
+		var blocks []*meta.Block
+		if len(points) > 0 {
+			blocks = append(blocks, m.seekTable(points))
+		}
+		blocks = append(blocks, comments)
+
+		buf := &bytes.Buffer{}
+		if _, err := flac.NewEncoder(buf, info, blocks...); err != nil {
+			return nil, err
+		}
+		leftover := space - (buf.Len() - skip)
+		switch {
+		case leftover == 0:
+			return buf.Bytes()[skip:], nil
+		case leftover >= flacBlockHeaderSize:
+			// the rest of the space stays as padding
+			blocks = append(blocks, &meta.Block{
+				Header: meta.Header{
+					Type:   meta.TypePadding,
+					Length: int64(leftover - flacBlockHeaderSize),
+				},
+			})
+			buf.Reset()
+			if _, err := flac.NewEncoder(buf, info, blocks...); err != nil {
+				return nil, err
+			}
+			return buf.Bytes()[skip:], nil
+		}
+
+		if len(points) == 0 {
+			return nil, errors.New("flac metadata does not fit in the space reserved for it")
+		}
+		// too long (or not enough room left over for padding), so make do with fewer seek points
+		thinned := make([]meta.SeekPoint, 0, len(points)/2)
+		for i := 0; i < len(points); i += 2 {
+			thinned = append(thinned, points[i])
+		}
+		if len(thinned) == len(points) {
+			thinned = nil
+		}
+		points = thinned
+	}
+}
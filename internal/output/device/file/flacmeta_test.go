@@ -0,0 +1,66 @@
+//go:build flac
+// +build flac
+
+package file
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/meta"
+
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+)
+
+func TestFlacMetadataFillsReserve(t *testing.T) {
+	info := meta.StreamInfo{
+		BlockSizeMin:  flacBlockSize,
+		BlockSizeMax:  flacBlockSize,
+		SampleRate:    44100,
+		NChannels:     2,
+		BitsPerSample: 16,
+	}
+
+	// a few points fit as they are, but far too many have to be thinned out
+	for _, nPoints := range []int{0, 10, 10000} {
+		m := flacMetadata{
+			song: &deviceCommon.SongInfo{Title: "test", Filepath: "/songs/test.mod", Format: "MOD"},
+		}
+		for i := 0; i < nPoints; i++ {
+			m.addSeekPoint(uint64(i*flacSeekInterval*44100), flacBlockSize, int64(i*1000), 44100)
+		}
+
+		md, err := m.encode(&info)
+		if err != nil {
+			t.Fatalf("%d points: %v", nPoints, err)
+		}
+		if len(md) != flacBlockHeaderSize+flacMetadataReserve {
+			t.Fatalf("%d points: expected %d bytes, got %d", nPoints, flacBlockHeaderSize+flacMetadataReserve, len(md))
+		}
+
+		buf := &bytes.Buffer{}
+		if _, err := flac.NewEncoder(buf, &info, newFlacReserve()); err != nil {
+			t.Fatal(err)
+		}
+		stream := append(buf.Bytes()[:flacStreamInfoPos+flacStreamInfoSize], md...)
+		s, err := flac.Parse(bytes.NewReader(stream))
+		if err != nil {
+			t.Fatalf("%d points: %v", nPoints, err)
+		}
+
+		var title string
+		for _, b := range s.Blocks {
+			if vc, ok := b.Body.(*meta.VorbisComment); ok {
+				for _, tag := range vc.Tags {
+					if tag[0] == "TITLE" {
+						title = tag[1]
+					}
+				}
+			}
+		}
+		if title != "test" {
+			t.Errorf("%d points: expected the title to be kept, got %q", nPoints, title)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+//go:build flac
+// +build flac
+
+package file
+
+import (
+	"bytes"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+	"github.com/gotracker/playback/output"
+)
+
+// flacStream is a flac file that never ends: its stream info leaves the length (and the
+// checksum) unknown, and every piece of the stream is made of whole frames
+type flacStream struct {
+	flattener        *deviceCommon.Flattener
+	samplesPerSecond int
+	bitsPerSample    int
+	channels         frame.Channels
+
+	buf     bytes.Buffer
+	enc     *flac.Encoder
+	header  []byte
+	pending [][]int32
+}
+
+func newFlacStream(settings deviceCommon.Settings) (StreamEncoder, error) {
+	flattener, err := deviceCommon.NewFlattener(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	s := flacStream{
+		flattener:        flattener,
+		samplesPerSecond: settings.SamplesPerSecond,
+		bitsPerSample:    settings.BitsPerSample,
+		pending:          make([][]int32, settings.Channels),
+	}
+
+	channels, err := getFlacChannels(settings.Channels)
+	if err != nil {
+		return nil, err
+	}
+	s.channels = channels
+
+	enc, err := flac.NewEncoder(&s.buf, &meta.StreamInfo{
+		BlockSizeMin:  flacBlockSize,
+		BlockSizeMax:  flacBlockSize,
+		SampleRate:    uint32(s.samplesPerSecond),
+		NChannels:     uint8(settings.Channels),
+		BitsPerSample: uint8(s.bitsPerSample),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the subframes get analyzed as they are built
+	enc.EnablePredictionAnalysis(false)
+	s.enc = enc
+	s.header = bytes.Clone(s.buf.Bytes())
+	s.buf.Reset()
+
+	return &s, nil
+}
+
+func (flacStream) ContentType() string {
+	return "audio/flac"
+}
+
+func (s flacStream) Header() []byte {
+	return s.header
+}
+
+func (s *flacStream) Encode(row *output.PremixData) ([]byte, error) {
+	mixedData := s.flattener.FlattenToInts(row, s.bitsPerSample)
+	for c := range s.pending {
+		s.pending[c] = append(s.pending[c], mixedData[c]...)
+	}
+
+	s.buf.Reset()
+	for len(s.pending[0]) >= flacBlockSize {
+		fr := newFlacFrame(s.pending, flacBlockSize, s.samplesPerSecond, s.bitsPerSample, s.channels)
+		if err := s.enc.WriteFrame(fr); err != nil {
+			return nil, err
+		}
+		for c := range s.pending {
+			s.pending[c] = append(s.pending[c][:0], s.pending[c][flacBlockSize:]...)
+		}
+	}
+	return bytes.Clone(s.buf.Bytes()), nil
+}
+
+func init() {
+	streamEncoderMap[".flac"] = newFlacStream
+	streamCapabilitiesMap[".flac"] = flacCapabilities
+}
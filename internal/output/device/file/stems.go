@@ -0,0 +1,217 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/output"
+)
+
+// stemSilenceLen is the most samples of silence sent to a stem in one go
+const stemSilenceLen = 4096
+
+// fileStems writes each tracker channel to its own file, and optionally the full mix as well.
+// Audio that can't be attributed to a tracker channel, such as background voices, OPL2 (adlib)
+// instruments and transitions between songs, only ends up in the full mix. The stems are what the tracker
+// channels played, before any effects or mastering were applied to the mix.
+type fileStems struct {
+	settings deviceCommon.Settings
+	factory  FileFactory
+
+	master *stemOutput
+	stems  []*stemOutput
+	// the number of samples sent to the stems so far, which a stem created later on has to catch up with
+	samplesLen int
+}
+
+type stemOutput struct {
+	file File
+	in   chan *output.PremixData
+}
+
+// NewStemsFactory returns a factory for files which write each tracker channel
+// to its own file created by `factory`
+func NewStemsFactory(factory FileFactory) FileFactory {
+	return func(settings deviceCommon.Settings) (File, error) {
+		fd := fileStems{
+			settings: settings,
+			factory:  factory,
+		}
+
+		if settings.StemsMaster {
+			master, err := factory(settings)
+			if err != nil {
+				return nil, err
+			}
+			fd.master = &stemOutput{
+				file: master,
+			}
+		}
+		return &fd, nil
+	}
+}
+
+// stemFilepath returns the filepath for the stem of the (0-based) channel `ch`,
+// e.g.: `song_ch01.wav` for the first channel of `song.wav`
+func stemFilepath(filename string, ch int) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s_ch%02d%s", strings.TrimSuffix(filename, ext), ch+1, ext)
+}
+
+// Play starts the stems output device playing
+func (d *fileStems) Play(in <-chan *output.PremixData, onWrittenCallback WrittenCallback) error {
+	return d.PlayWithCtx(context.Background(), in, onWrittenCallback)
+}
+
+// PlayWithCtx starts the stems output device playing
+func (d *fileStems) PlayWithCtx(ctx context.Context, in <-chan *output.PremixData, onWrittenCallback WrittenCallback) error {
+	myCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	start := func(s *stemOutput) {
+		s.in = make(chan *output.PremixData, 16)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.file.PlayWithCtx(myCtx, s.in, nil); err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+				cancel()
+			}
+		}()
+	}
+	stop := func() error {
+		if d.master != nil && d.master.in != nil {
+			close(d.master.in)
+		}
+		for _, s := range d.stems {
+			close(s.in)
+		}
+		wg.Wait()
+		if firstErr != nil && !errors.Is(firstErr, context.Canceled) {
+			return firstErr
+		}
+		return myCtx.Err()
+	}
+
+	if d.master != nil {
+		start(d.master)
+	}
+
+	for {
+		select {
+		case <-myCtx.Done():
+			return stop()
+		case row, ok := <-in:
+			if !ok {
+				return stop()
+			}
+
+			// the channel data is shared between all the files, so finish mixing it in before handing it out
+			for _, rdata := range row.Data {
+				for i := range rdata {
+					if rdata[i].Flush != nil {
+						rdata[i].Flush()
+						rdata[i].Flush = nil
+					}
+				}
+			}
+
+			// the tracker channels can cover a different number of samples than the mix, when it's been
+			// processed by something holding some of it back (such as the look-ahead of a limiter)
+			channels, samplesLen := deviceCommon.TrackerChannels(row)
+			for len(d.stems) < len(channels) {
+				s, err := d.newStem(len(d.stems))
+				if err != nil {
+					cancel()
+					_ = stop()
+					return err
+				}
+				start(s)
+				// start the new stem with the silence it missed out on
+				for silence := d.samplesLen; silence > 0; silence -= stemSilenceLen {
+					if !d.send(myCtx, s, &output.PremixData{
+						SamplesLen:  min(silence, stemSilenceLen),
+						MixerVolume: row.MixerVolume,
+					}) {
+						return stop()
+					}
+				}
+			}
+
+			if d.master != nil && !d.send(myCtx, d.master, row) {
+				return stop()
+			}
+			for ch, s := range d.stems {
+				premix := output.PremixData{
+					SamplesLen:  samplesLen,
+					MixerVolume: row.MixerVolume,
+					Userdata:    deviceCommon.Userdata(row),
+				}
+				if ch < len(channels) {
+					premix.Data = []mixing.ChannelData{channels[ch : ch+1]}
+				}
+				if !d.send(myCtx, s, &premix) {
+					return stop()
+				}
+			}
+			d.samplesLen += samplesLen
+
+			if onWrittenCallback != nil {
+				onWrittenCallback(row)
+			}
+		}
+	}
+}
+
+func (d *fileStems) newStem(ch int) (*stemOutput, error) {
+	settings := d.settings
+	settings.Filepath = stemFilepath(d.settings.Filepath, ch)
+	f, err := d.factory(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &stemOutput{
+		file: f,
+	}
+	d.stems = append(d.stems, s)
+	return s, nil
+}
+
+func (d *fileStems) send(ctx context.Context, s *stemOutput, premix *output.PremixData) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case s.in <- premix:
+		return true
+	}
+}
+
+// Close closes all the files of the stems output device
+func (d *fileStems) Close() error {
+	var firstErr error
+	if d.master != nil {
+		firstErr = d.master.file.Close()
+	}
+	for _, s := range d.stems {
+		if err := s.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
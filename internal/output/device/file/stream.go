@@ -0,0 +1,93 @@
+package file
+
+import (
+	"sort"
+
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+	"github.com/gotracker/playback/output"
+)
+
+var (
+	streamEncoderMap      = make(map[string]StreamEncoderFactory)
+	streamCapabilitiesMap = make(map[string]deviceCommon.Capabilities)
+	rawCapabilities       = deviceCommon.Capabilities{
+		MinSampleRate: 1,
+		BitsPerSample: []int{8, 16, 24, 32},
+		Channels:      deviceCommon.MixerChannels,
+		SampleFormats: true,
+	}
+)
+
+type StreamEncoderFactory func(settings deviceCommon.Settings) (StreamEncoder, error)
+
+// StreamEncoder encodes rows into an endless stream, which is made up of a header followed
+// by pieces that can each be the first one a listener gets after the header
+type StreamEncoder interface {
+	// ContentType returns the MIME type of the stream
+	ContentType() string
+	// Header returns what has to come before the first piece of the stream
+	Header() []byte
+	// Encode returns the piece of the stream holding `row`, which may be empty
+	// when the encoder holds onto the row until it has enough to encode
+	Encode(row *output.PremixData) ([]byte, error)
+}
+
+func GetStreamEncoder(extension string) (StreamEncoderFactory, bool) {
+	factory, ok := streamEncoderMap[extension]
+	return factory, ok
+}
+
+// GetStreamCapabilities returns the formats any of the stream encoders can encode
+func GetStreamCapabilities() deviceCommon.Capabilities {
+	return unionCapabilities(streamCapabilitiesMap)
+}
+
+// GetStreamEncoderExtensions returns the (sorted) extensions of the available stream encoders
+func GetStreamEncoderExtensions() []string {
+	extensions := make([]string, 0, len(streamEncoderMap))
+	for ext := range streamEncoderMap {
+		extensions = append(extensions, ext)
+	}
+	sort.Strings(extensions)
+	return extensions
+}
+
+// rawStream is a stream of interleaved raw PCM, with no header
+type rawStream struct {
+	flattener *deviceCommon.Flattener
+	sampFmt   deviceCommon.SampleFormat
+}
+
+func newRawStream(settings deviceCommon.Settings) (StreamEncoder, error) {
+	flattener, err := deviceCommon.NewFlattener(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	sampFmt, err := deviceCommon.GetSampleFormat(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rawStream{
+		flattener: flattener,
+		sampFmt:   sampFmt,
+	}, nil
+}
+
+func (rawStream) ContentType() string {
+	return "application/octet-stream"
+}
+
+func (rawStream) Header() []byte {
+	return nil
+}
+
+func (s *rawStream) Encode(row *output.PremixData) ([]byte, error) {
+	return s.flattener.Flatten(row, s.sampFmt), nil
+}
+
+func init() {
+	streamEncoderMap[".raw"] = newRawStream
+	streamCapabilitiesMap[".raw"] = rawCapabilities
+}
@@ -5,97 +5,302 @@ import (
 	"context"
 	"encoding/binary"
 	"errors"
+	"io"
+	"math"
 	"os"
 
 	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
 	"github.com/gotracker/playback/mixing"
-	"github.com/gotracker/playback/mixing/sampling"
 	"github.com/gotracker/playback/output"
 )
 
 type fileWav struct {
-	mix     mixing.Mixer
-	sampFmt sampling.Format
+	flattener *deviceCommon.Flattener
+	sampFmt   deviceCommon.SampleFormat
+	format    WavFormat
 
-	f  *os.File
-	w  *bufio.Writer
-	sz uint32
+	f       *os.File
+	w       *bufio.Writer
+	sz      uint64
+	markers wavMarkers
 }
 
 const (
-	wavFileChunkSizePos     = 4
-	wavFileSubchunk2SizePos = 40
+	wavFileChunkSizePos = 4
+	wavDS64Pos          = 12
+	// the ds64 chunk holds the 64-bit RIFF size, data size and sample count, followed by an empty table
+	wavDS64ChunkDataSize = 8 + 8 + 8 + 4
+	wavDS64ChunkSize     = 8 + wavDS64ChunkDataSize
+
+	wavFormatPCM        = 0x0001 // = win32.WAVE_FORMAT_PCM
+	wavFormatIEEEFloat  = 0x0003 // = win32.WAVE_FORMAT_IEEE_FLOAT
+	wavFormatExtensible = 0xFFFE // = win32.WAVE_FORMAT_EXTENSIBLE
 )
 
-func newFileWavDevice(settings deviceCommon.Settings) (File, error) {
-	fd := fileWav{
-		mix: mixing.Mixer{
-			Channels: settings.Channels,
-		},
+var (
+	wavCapabilities = deviceCommon.Capabilities{
+		MinSampleRate: 1,
+		BitsPerSample: []int{8, 16, 24, 32},
+		Channels:      deviceCommon.MixerChannels,
+		SampleFormats: true,
 	}
-	switch settings.BitsPerSample {
-	case 8:
-		fd.sampFmt = sampling.Format8BitSigned
-	case 16:
-		fd.sampFmt = sampling.Format16BitLESigned
+
+	// the (little-endian) format tag, followed by the rest of the KSDATAFORMAT_SUBTYPE guid
+	wavSubFormatGUIDTail = [14]byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+)
+
+// WavFormat is the format of the sample data in a wave file
+type WavFormat struct {
+	Channels         int
+	SamplesPerSecond int
+	BitsPerSample    int
+	// Float is set when the samples are IEEE floating point values instead of integers
+	Float bool
+}
+
+// extensible returns true if the format needs a WAVE_FORMAT_EXTENSIBLE header,
+// which is the case for anything beyond 8- or 16-bit integer mono or stereo
+func (f WavFormat) extensible() bool {
+	return f.Channels > 2 || f.BitsPerSample > 16 || f.Float
+}
+
+// formatTag returns the format of the samples, as written to the fmt chunk (or the extensible sub-format)
+func (f WavFormat) formatTag() uint16 {
+	if f.Float {
+		return wavFormatIEEEFloat
 	}
+	return wavFormatPCM
+}
 
-	f, err := os.OpenFile(settings.Filepath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
-	if err != nil {
-		return nil, err
+// channelMask returns the speaker positions of the channels, in the order the mixer outputs them
+func (f WavFormat) channelMask() uint32 {
+	switch f.Channels {
+	case 1:
+		return 0x4 // FRONT_CENTER
+	case 2:
+		return 0x1 | 0x2 // FRONT_LEFT | FRONT_RIGHT
+	case 4:
+		return 0x1 | 0x2 | 0x10 | 0x20 // FRONT_LEFT | FRONT_RIGHT | BACK_LEFT | BACK_RIGHT
 	}
+	// unspecified
+	return 0
+}
 
-	if f == nil {
-		return nil, errors.New("unexpected file error")
+func (f WavFormat) blockAlign() int {
+	return f.Channels * f.BitsPerSample / 8
+}
+
+func (f WavFormat) fmtChunkSize() uint32 {
+	if f.extensible() {
+		return 40
 	}
+	return 16
+}
 
-	byteRate := settings.SamplesPerSecond * settings.Channels * settings.BitsPerSample / 8
-	blockAlign := settings.Channels * settings.BitsPerSample / 8
+// hasFactChunk returns true if the format needs a fact chunk, which is required for anything but integer samples
+func (f WavFormat) hasFactChunk() bool {
+	return f.Float
+}
+
+// factSampleLengthPos returns the position of the sample length in the fact chunk
+func (f WavFormat) factSampleLengthPos() int64 {
+	return 12 + 8 + int64(f.fmtChunkSize()) + 8
+}
+
+// headerSize returns the number of bytes before the sample data
+func (f WavFormat) headerSize() uint32 {
+	size := 12 + 8 + f.fmtChunkSize() + 8
+	if f.hasFactChunk() {
+		size += 12
+	}
+	return size
+}
+
+// dataSizePos returns the position of the data chunk size
+func (f WavFormat) dataSizePos() int64 {
+	return int64(f.headerSize()) - 4
+}
+
+// WriteWav writes a complete wave file containing `data`, followed by any extra `chunks`
+func WriteWav(w io.Writer, format WavFormat, data []byte, chunks ...WavChunk) error {
+	var extraSize uint32
+	if len(data)%2 != 0 {
+		// chunks are word-aligned
+		extraSize++
+	}
+	for _, c := range chunks {
+		extraSize += c.size()
+	}
+
+	if err := writeWavHeader(w, format, uint32(len(data)), extraSize, false); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if len(data)%2 != 0 {
+		if _, err := w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range chunks {
+		if err := c.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeWavHeader writes the RIFF, fmt, fact (when needed) and data chunk headers of a wave file.
+// `extraSize` is the number of bytes that follow the sample data.
+// When `reserveDS64` is set, room is left for a ds64 chunk (as a JUNK chunk) right after the RIFF
+// header, so that the file can be turned into an RF64 file once the sizes no longer fit in 32 bits.
+func writeWavHeader(w io.Writer, format WavFormat, dataSize uint32, extraSize uint32, reserveDS64 bool) error {
+	byteRate := format.SamplesPerSecond * format.blockAlign()
+	headerSize := format.headerSize()
+	if reserveDS64 {
+		headerSize += wavDS64ChunkSize
+	}
 
-	w := bufio.NewWriter(f)
 	// RIFF header
 	if _, err := w.Write([]byte{'R', 'I', 'F', 'F'}); err != nil { // ChunkID
-		return nil, err
+		return err
 	}
-	if err := binary.Write(w, binary.LittleEndian, uint32(0)); err != nil { // ChunkSize
-		return nil, err
+	if err := binary.Write(w, binary.LittleEndian, uint32(headerSize-8+dataSize+extraSize)); err != nil { // ChunkSize
+		return err
 	}
 	if _, err := w.Write([]byte{'W', 'A', 'V', 'E'}); err != nil { // Format
-		return nil, err
+		return err
+	}
+
+	// JUNK header (the future ds64 chunk)
+	if reserveDS64 {
+		if _, err := w.Write([]byte{'J', 'U', 'N', 'K'}); err != nil { // ChunkID
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(wavDS64ChunkDataSize)); err != nil { // ChunkSize
+			return err
+		}
+		if _, err := w.Write(make([]byte, wavDS64ChunkDataSize)); err != nil {
+			return err
+		}
 	}
 
 	// fmt header
 	if _, err := w.Write([]byte{'f', 'm', 't', ' '}); err != nil { // Subchunk1ID
-		return nil, err
+		return err
 	}
-	if err := binary.Write(w, binary.LittleEndian, uint32(16)); err != nil { // Subchunk1Size
-		return nil, err
+	if err := binary.Write(w, binary.LittleEndian, format.fmtChunkSize()); err != nil { // Subchunk1Size
+		return err
 	}
 	// = win32.WAVEFORMATEX (before the CbSize)
-	if err := binary.Write(w, binary.LittleEndian, uint16(0x001)); err != nil { // AudioFormat // = win32.WAVE_FORMAT_PCM
-		return nil, err
+	formatTag := format.formatTag()
+	if format.extensible() {
+		formatTag = wavFormatExtensible
 	}
-	if err := binary.Write(w, binary.LittleEndian, uint16(settings.Channels)); err != nil { // NumChannels
-		return nil, err
+	if err := binary.Write(w, binary.LittleEndian, formatTag); err != nil { // AudioFormat
+		return err
 	}
-	if err := binary.Write(w, binary.LittleEndian, uint32(settings.SamplesPerSecond)); err != nil { // SampleRate
-		return nil, err
+	if err := binary.Write(w, binary.LittleEndian, uint16(format.Channels)); err != nil { // NumChannels
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(format.SamplesPerSecond)); err != nil { // SampleRate
+		return err
 	}
 	if err := binary.Write(w, binary.LittleEndian, uint32(byteRate)); err != nil { // ByteRate
-		return nil, err
+		return err
 	}
-	if err := binary.Write(w, binary.LittleEndian, uint16(blockAlign)); err != nil { // BlockAlign
-		return nil, err
+	if err := binary.Write(w, binary.LittleEndian, uint16(format.blockAlign())); err != nil { // BlockAlign
+		return err
 	}
-	if err := binary.Write(w, binary.LittleEndian, uint16(settings.BitsPerSample)); err != nil { // BitsPerSample
-		return nil, err
+	if err := binary.Write(w, binary.LittleEndian, uint16(format.BitsPerSample)); err != nil { // BitsPerSample
+		return err
+	}
+
+	if format.extensible() {
+		// = win32.WAVEFORMATEXTENSIBLE (from the CbSize)
+		if err := binary.Write(w, binary.LittleEndian, uint16(22)); err != nil { // CbSize
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint16(format.BitsPerSample)); err != nil { // ValidBitsPerSample
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, format.channelMask()); err != nil { // ChannelMask
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, format.formatTag()); err != nil { // SubFormat
+			return err
+		}
+		if _, err := w.Write(wavSubFormatGUIDTail[:]); err != nil {
+			return err
+		}
+	}
+
+	// fact header
+	if format.hasFactChunk() {
+		if _, err := w.Write([]byte{'f', 'a', 'c', 't'}); err != nil { // ChunkID
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(4)); err != nil { // ChunkSize
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, dataSize/uint32(format.blockAlign())); err != nil { // SampleLength
+			return err
+		}
 	}
 
 	// data header
 	if _, err := w.Write([]byte{'d', 'a', 't', 'a'}); err != nil { // Subchunk2ID
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, dataSize); err != nil { // Subchunk2Size
+		return err
+	}
+	return nil
+}
+
+func newFileWavDevice(settings deviceCommon.Settings) (File, error) {
+	flattener, err := deviceCommon.NewFlattener(settings)
+	if err != nil {
 		return nil, err
 	}
-	if err := binary.Write(w, binary.LittleEndian, uint32(0)); err != nil { // Subchunk2Size
+
+	sampFmt, err := deviceCommon.GetSampleFormat(settings)
+	if err != nil {
+		return nil, err
+	}
+	if sampFmt == deviceCommon.SampleFormatS8 {
+		return nil, errors.New("wave files only support unsigned 8-bit samples")
+	}
+
+	fd := fileWav{
+		flattener: flattener,
+		sampFmt:   sampFmt,
+		format: WavFormat{
+			Channels:         settings.Channels,
+			SamplesPerSecond: settings.SamplesPerSecond,
+			BitsPerSample:    sampFmt.BitsPerSample(),
+			Float:            sampFmt.IsFloat(),
+		},
+		markers: wavMarkers{
+			cueSongs: settings.CueSongs,
+		},
+	}
+
+	f, err := os.OpenFile(settings.Filepath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if f == nil {
+		return nil, errors.New("unexpected file error")
+	}
+
+	w := bufio.NewWriter(f)
+	// the sizes get filled in when the device is closed
+	if err := writeWavHeader(w, fd.format, 0, 0, true); err != nil {
+		f.Close()
 		return nil, err
 	}
 
@@ -112,7 +317,7 @@ func (d *fileWav) Play(in <-chan *output.PremixData, onWrittenCallback WrittenCa
 
 // PlayWithCtx starts the wave output device playing
 func (d *fileWav) PlayWithCtx(ctx context.Context, in <-chan *output.PremixData, onWrittenCallback WrittenCallback) error {
-	panmixer := mixing.GetPanMixer(d.mix.Channels)
+	panmixer := mixing.GetPanMixer(d.flattener.Channels())
 	if panmixer == nil {
 		return errors.New("invalid pan mixer - check channel count")
 	}
@@ -128,12 +333,13 @@ func (d *fileWav) PlayWithCtx(ctx context.Context, in <-chan *output.PremixData,
 			if !ok {
 				return nil
 			}
-			mixedData := d.mix.Flatten(row.SamplesLen, row.Data, row.MixerVolume, d.sampFmt)
+			d.markers.update(deviceCommon.Userdata(row), d.sz/uint64(d.format.blockAlign()))
+			mixedData := d.flattener.Flatten(row, d.sampFmt)
 			sz, err := d.w.Write(mixedData)
 			if err != nil {
 				return err
 			}
-			d.sz += uint32(sz)
+			d.sz += uint64(sz)
 			if onWrittenCallback != nil {
 				onWrittenCallback(row)
 			}
@@ -143,27 +349,87 @@ func (d *fileWav) PlayWithCtx(ctx context.Context, in <-chan *output.PremixData,
 
 // Close closes the wave output device
 func (d *fileWav) Close() error {
-	d.w.Flush()
-	chunkSize := 36 + d.sz
-	if _, err := d.f.Seek(wavFileChunkSizePos, 0); err != nil {
-		return err
+	// the file is closed even if it couldn't be finished off, with the first thing to go wrong being reported
+	err := d.finish()
+	if closeErr := d.f.Close(); err == nil {
+		err = closeErr
 	}
-	if err := binary.Write(d.w, binary.LittleEndian, uint32(chunkSize)); err != nil { // ChunkSize
-		return err
+	return err
+}
+
+// finish writes out the rest of the file: the metadata after the sample data, and the sizes in the header
+func (d *fileWav) finish() error {
+	var extraSize uint64
+	if d.sz%2 != 0 {
+		// chunks are word-aligned
+		if err := d.w.WriteByte(0); err != nil {
+			return err
+		}
+		extraSize++
+	}
+	for _, c := range d.markers.chunks() {
+		if err := c.write(d.w); err != nil {
+			return err
+		}
+		extraSize += uint64(c.size())
 	}
-	if _, err := d.f.Seek(wavFileSubchunk2SizePos, 0); err != nil {
+
+	if err := d.w.Flush(); err != nil {
 		return err
 	}
-	if err := binary.Write(d.w, binary.LittleEndian, uint32(d.sz)); err != nil { // Subchunk2Size
+	d.w = nil
+
+	return d.writeSizes(extraSize)
+}
+
+// writeSizes fills in the sizes in the header, switching over to RF64 when they don't fit in 32 bits.
+// `extraSize` is the number of bytes that follow the sample data.
+func (d *fileWav) writeSizes(extraSize uint64) error {
+	headerSize := uint64(d.format.headerSize()) + wavDS64ChunkSize
+	riffSize := headerSize - 8 + d.sz + extraSize
+	sampleCount := d.sz / uint64(d.format.blockAlign())
+
+	var chunkSize, sampleLength, dataSize uint32
+	if riffSize <= math.MaxUint32 {
+		chunkSize, sampleLength, dataSize = uint32(riffSize), uint32(sampleCount), uint32(d.sz)
+	} else {
+		// RF64: the real sizes go in the ds64 chunk (in place of the JUNK chunk), with -1 in their old places
+		chunkSize, sampleLength, dataSize = math.MaxUint32, math.MaxUint32, math.MaxUint32
+
+		if _, err := d.f.WriteAt([]byte{'R', 'F', '6', '4'}, 0); err != nil { // ChunkID
+			return err
+		}
+		ds64 := make([]byte, 0, wavDS64ChunkSize)
+		ds64 = append(ds64, 'd', 's', '6', '4')
+		ds64 = binary.LittleEndian.AppendUint32(ds64, wavDS64ChunkDataSize)
+		ds64 = binary.LittleEndian.AppendUint64(ds64, riffSize)    // RIFFSize
+		ds64 = binary.LittleEndian.AppendUint64(ds64, d.sz)        // DataSize
+		ds64 = binary.LittleEndian.AppendUint64(ds64, sampleCount) // SampleCount
+		ds64 = binary.LittleEndian.AppendUint32(ds64, 0)           // TableLength
+		if _, err := d.f.WriteAt(ds64, wavDS64Pos); err != nil {
+			return err
+		}
+	}
+
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], chunkSize)
+	if _, err := d.f.WriteAt(buf[:], wavFileChunkSizePos); err != nil { // ChunkSize
 		return err
 	}
-	if err := d.w.Flush(); err != nil {
+	if d.format.hasFactChunk() {
+		binary.LittleEndian.PutUint32(buf[:], sampleLength)
+		if _, err := d.f.WriteAt(buf[:], d.format.factSampleLengthPos()+wavDS64ChunkSize); err != nil { // SampleLength
+			return err
+		}
+	}
+	binary.LittleEndian.PutUint32(buf[:], dataSize)
+	if _, err := d.f.WriteAt(buf[:], d.format.dataSizePos()+wavDS64ChunkSize); err != nil { // Subchunk2Size
 		return err
 	}
-	d.w = nil
-	return d.f.Close()
+	return nil
 }
 
 func init() {
 	fileDeviceMap[".wav"] = newFileWavDevice
+	fileCapabilitiesMap[".wav"] = wavCapabilities
 }
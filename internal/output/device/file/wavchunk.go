@@ -0,0 +1,158 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// WavChunk is an extra RIFF chunk of a wave file, such as its metadata
+type WavChunk struct {
+	ID   [4]byte
+	Data []byte
+}
+
+// size returns the number of bytes the chunk takes up in the file, including its header and padding
+func (c WavChunk) size() uint32 {
+	return 8 + uint32(len(c.Data)+len(c.Data)%2)
+}
+
+func (c WavChunk) write(w io.Writer) error {
+	if _, err := w.Write(c.ID[:]); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(c.Data))); err != nil {
+		return err
+	}
+	if _, err := w.Write(c.Data); err != nil {
+		return err
+	}
+	if len(c.Data)%2 != 0 {
+		// chunks are word-aligned
+		if _, err := w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WavLoop is a sample loop, in sample frames
+type WavLoop struct {
+	Begin    int
+	End      int // exclusive
+	PingPong bool
+}
+
+// NewWavSamplerChunk returns a `smpl` chunk describing the loops of a sample
+// whose middle C plays at `samplesPerSecond`
+func NewWavSamplerChunk(samplesPerSecond int, loops ...WavLoop) WavChunk {
+	var samplePeriod uint32
+	if samplesPerSecond > 0 {
+		samplePeriod = uint32(1_000_000_000 / samplesPerSecond)
+	}
+
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, [9]uint32{
+		0,                  // Manufacturer
+		0,                  // Product
+		samplePeriod,       // SamplePeriod (in nanoseconds)
+		60,                 // MIDIUnityNote (middle C)
+		0,                  // MIDIPitchFraction
+		0,                  // SMPTEFormat
+		0,                  // SMPTEOffset
+		uint32(len(loops)), // NumSampleLoops
+		0,                  // SamplerData
+	})
+	for i, l := range loops {
+		var loopType uint32 // forward
+		if l.PingPong {
+			loopType = 1 // alternating
+		}
+		binary.Write(buf, binary.LittleEndian, [6]uint32{
+			uint32(i),         // CuePointID
+			loopType,          // Type
+			uint32(l.Begin),   // Start
+			uint32(l.End - 1), // End (inclusive)
+			0,                 // Fraction
+			0,                 // PlayCount (forever)
+		})
+	}
+
+	return WavChunk{
+		ID:   [4]byte{'s', 'm', 'p', 'l'},
+		Data: buf.Bytes(),
+	}
+}
+
+// WavInfoTag is a single entry of a `LIST` `INFO` chunk, such as `INAM` (the name)
+type WavInfoTag struct {
+	ID    [4]byte
+	Value string
+}
+
+// NewWavInfoChunk returns a `LIST` chunk of the `INFO` type holding the `tags`
+func NewWavInfoChunk(tags ...WavInfoTag) WavChunk {
+	buf := &bytes.Buffer{}
+	buf.WriteString("INFO")
+	for _, tag := range tags {
+		sub := WavChunk{
+			ID:   tag.ID,
+			Data: append([]byte(tag.Value), 0),
+		}
+		sub.write(buf)
+	}
+
+	return WavChunk{
+		ID:   [4]byte{'L', 'I', 'S', 'T'},
+		Data: buf.Bytes(),
+	}
+}
+
+// WavCue is a marker in a wave file, such as the start of an order
+type WavCue struct {
+	Position uint32 // in sample frames
+	Label    string
+}
+
+// NewWavCueChunk returns a `cue ` chunk holding the positions of the `cues`
+func NewWavCueChunk(cues ...WavCue) WavChunk {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, uint32(len(cues))) // NumCuePoints
+	for i, c := range cues {
+		binary.Write(buf, binary.LittleEndian, [2]uint32{
+			uint32(i + 1), // ID
+			c.Position,    // Position
+		})
+		buf.WriteString("data") // DataChunkID
+		binary.Write(buf, binary.LittleEndian, [3]uint32{
+			0,          // ChunkStart
+			0,          // BlockStart
+			c.Position, // SampleOffset
+		})
+	}
+
+	return WavChunk{
+		ID:   [4]byte{'c', 'u', 'e', ' '},
+		Data: buf.Bytes(),
+	}
+}
+
+// NewWavLabelChunk returns a `LIST` chunk of the `adtl` type holding the labels of the `cues`
+// (as written by NewWavCueChunk)
+func NewWavLabelChunk(cues ...WavCue) WavChunk {
+	buf := &bytes.Buffer{}
+	buf.WriteString("adtl")
+	for i, c := range cues {
+		data := binary.LittleEndian.AppendUint32(nil, uint32(i+1)) // CuePointID
+		sub := WavChunk{
+			ID:   [4]byte{'l', 'a', 'b', 'l'},
+			Data: append(append(data, c.Label...), 0),
+		}
+		sub.write(buf)
+	}
+
+	return WavChunk{
+		ID:   [4]byte{'L', 'I', 'S', 'T'},
+		Data: buf.Bytes(),
+	}
+}
@@ -0,0 +1,89 @@
+package file
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+	"github.com/gotracker/playback/player/render"
+)
+
+// softwareName is how the rendered files name the software that made them
+const softwareName = "Gotracker"
+
+// wavMarkers collects the metadata of a rendered wave file: the details of the (first) song,
+// along with a cue point at every order change and, optionally, at the start of every song
+type wavMarkers struct {
+	cueSongs bool
+
+	song      *deviceCommon.SongInfo
+	cues      []WavCue
+	lastOrder int
+	inOrder   bool
+}
+
+// update notes the song or row about to be output at `pos` (in sample frames)
+func (m *wavMarkers) update(userdata any, pos uint64) {
+	switch ud := userdata.(type) {
+	case *deviceCommon.SongInfo:
+		if m.song == nil {
+			m.song = ud
+		}
+		if m.cueSongs {
+			label := ud.Title
+			if label == "" {
+				label = filepath.Base(ud.Filepath)
+			}
+			m.addCue(pos, label)
+		}
+		// the new song always gets a cue for its first order
+		m.inOrder = false
+
+	case *render.RowRender:
+		if m.inOrder && ud.Order == m.lastOrder {
+			return
+		}
+		m.lastOrder = ud.Order
+		m.inOrder = true
+		m.addCue(pos, fmt.Sprintf("Order %d", ud.Order))
+	}
+}
+
+func (m *wavMarkers) addCue(pos uint64, label string) {
+	if pos > math.MaxUint32 {
+		// cue points can't reach this far
+		return
+	}
+	m.cues = append(m.cues, WavCue{
+		Position: uint32(pos),
+		Label:    label,
+	})
+}
+
+// chunks returns the chunks holding the metadata
+func (m *wavMarkers) chunks() []WavChunk {
+	tags := []WavInfoTag{
+		{ID: [4]byte{'I', 'S', 'F', 'T'}, Value: softwareName},
+	}
+	if m.song != nil {
+		if m.song.Title != "" {
+			tags = append(tags, WavInfoTag{ID: [4]byte{'I', 'N', 'A', 'M'}, Value: m.song.Title})
+		}
+		if m.song.Artist != "" {
+			tags = append(tags, WavInfoTag{ID: [4]byte{'I', 'A', 'R', 'T'}, Value: m.song.Artist})
+		}
+		if m.song.Filepath != "" {
+			tags = append(tags, WavInfoTag{ID: [4]byte{'I', 'S', 'R', 'C'}, Value: filepath.Base(m.song.Filepath)})
+		}
+		if m.song.Format != "" {
+			tags = append(tags, WavInfoTag{ID: [4]byte{'I', 'S', 'R', 'F'}, Value: m.song.Format})
+		}
+	}
+
+	chunks := []WavChunk{NewWavInfoChunk(tags...)}
+	if len(m.cues) > 0 {
+		chunks = append(chunks, NewWavCueChunk(m.cues...), NewWavLabelChunk(m.cues...))
+	}
+	return chunks
+}
@@ -0,0 +1,70 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"math"
+
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+	"github.com/gotracker/playback/output"
+)
+
+// wavStream is a wave file that never ends: its sizes are as large as they can be,
+// which is how players expect to be told that the length of a stream is not known
+type wavStream struct {
+	flattener *deviceCommon.Flattener
+	sampFmt   deviceCommon.SampleFormat
+	header    []byte
+}
+
+func newWavStream(settings deviceCommon.Settings) (StreamEncoder, error) {
+	flattener, err := deviceCommon.NewFlattener(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	sampFmt, err := deviceCommon.GetSampleFormat(settings)
+	if err != nil {
+		return nil, err
+	}
+	if sampFmt == deviceCommon.SampleFormatS8 {
+		return nil, errors.New("wave files only support unsigned 8-bit samples")
+	}
+
+	format := WavFormat{
+		Channels:         settings.Channels,
+		SamplesPerSecond: settings.SamplesPerSecond,
+		BitsPerSample:    sampFmt.BitsPerSample(),
+		Float:            sampFmt.IsFloat(),
+	}
+
+	// the data size makes the RIFF size come out at its maximum, too
+	buf := &bytes.Buffer{}
+	dataSize := uint32(math.MaxUint32 - (format.headerSize() - 8))
+	if err := writeWavHeader(buf, format, dataSize, 0, false); err != nil {
+		return nil, err
+	}
+
+	return &wavStream{
+		flattener: flattener,
+		sampFmt:   sampFmt,
+		header:    buf.Bytes(),
+	}, nil
+}
+
+func (wavStream) ContentType() string {
+	return "audio/wav"
+}
+
+func (s wavStream) Header() []byte {
+	return s.header
+}
+
+func (s *wavStream) Encode(row *output.PremixData) ([]byte, error) {
+	return s.flattener.Flatten(row, s.sampFmt), nil
+}
+
+func init() {
+	streamEncoderMap[".wav"] = newWavStream
+	streamCapabilitiesMap[".wav"] = wavCapabilities
+}
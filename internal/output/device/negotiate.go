@@ -0,0 +1,78 @@
+package device
+
+import (
+	"fmt"
+	"strings"
+
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+)
+
+// Negotiate fits the sample rate and channel count of `settings` to the nearest that the devices named in them
+// can play, warning about whatever had to change. The devices of a tee all play the same mix, so it's the
+// nearest that they have in common.
+func Negotiate(settings *deviceCommon.Settings) error {
+	specs := []deviceSpec{{settings: *settings}}
+	if strings.ContainsAny(settings.Name, teeSeparator+teeOptionSeparator) {
+		var err error
+		if specs, err = parseDeviceSpecs(*settings); err != nil {
+			return err
+		}
+	}
+
+	var (
+		caps  deviceCommon.Capabilities
+		names []string
+	)
+	for _, spec := range specs {
+		details, ok := Map[spec.settings.Name]
+		if !ok {
+			// creating it says what's wrong with it
+			continue
+		}
+		c := details.getCapabilities(spec.settings)
+		if len(names) == 0 {
+			caps = c
+		} else {
+			caps = caps.Intersect(c)
+		}
+		names = append(names, spec.settings.Name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	name := strings.Join(names, teeSeparator)
+	if len(caps.Channels) == 0 || (caps.MaxSampleRate != 0 && caps.MaxSampleRate < caps.MinSampleRate) {
+		return fmt.Errorf("%s: the devices have no sample rate and channel count in common", name)
+	}
+
+	if rate := caps.NearestSampleRate(settings.SamplesPerSecond); rate != settings.SamplesPerSecond {
+		warnf(*settings, "%s: can't play a sample rate of %d (supported: %s) - using %d instead\n", name, settings.SamplesPerSecond, caps.SampleRatesString(), rate)
+		settings.SamplesPerSecond = rate
+	}
+	if channels := caps.NearestChannels(settings.Channels); channels != settings.Channels {
+		warnf(*settings, "%s: can't play %d channels (supported: %s) - using %d instead\n", name, settings.Channels, caps.ChannelsString(), channels)
+		settings.Channels = channels
+	}
+	return nil
+}
+
+// negotiateBitsPerSample fits the sample size of `settings` to the nearest the device can play, warning if it had to change.
+// Unlike the sample rate and channel count, it can be different for each device of a tee.
+func negotiateBitsPerSample(details deviceDetails, settings *deviceCommon.Settings) {
+	caps := details.getCapabilities(*settings)
+	if caps.SampleFormats && settings.SampleFormat != "" {
+		// the sample format decides the size
+		return
+	}
+	if bits := caps.NearestBitsPerSample(settings.BitsPerSample); bits != settings.BitsPerSample {
+		warnf(*settings, "%s: can't play %d bits per sample (supported: %s) - using %d instead\n", settings.Name, settings.BitsPerSample, caps.BitsPerSampleString(), bits)
+		settings.BitsPerSample = bits
+	}
+}
+
+func warnf(settings deviceCommon.Settings, format string, args ...any) {
+	if settings.Messages != nil {
+		fmt.Fprintf(settings.Messages, "warning: "+format, args...)
+	}
+}
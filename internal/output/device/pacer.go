@@ -0,0 +1,42 @@
+package device
+
+import (
+	"context"
+	"time"
+)
+
+// realTimePacer holds output back to the pace it would be heard at, for devices that aren't
+// held back by anything else (like a sound card would be), letting it get ahead by up to `lead`
+type realTimePacer struct {
+	samplesPerSecond int
+	lead             time.Duration
+
+	start  time.Time
+	played time.Duration
+}
+
+// pace waits until the output is no further than the lead ahead of real time,
+// having just output `samples` samples
+func (p *realTimePacer) pace(ctx context.Context, samples int) error {
+	now := time.Now()
+	if p.start.IsZero() || now.Sub(p.start)-p.played > p.lead {
+		// nothing's been output yet, or nothing was output for a while (e.g.: while paused),
+		// so the clock starts over
+		p.start = now
+		p.played = 0
+	}
+	p.played += time.Duration(samples) * time.Second / time.Duration(p.samplesPerSecond)
+
+	wait := p.played - now.Sub(p.start) - p.lead
+	if wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -5,24 +5,88 @@ package pulseaudio
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jfreymuth/pulse"
 	"github.com/jfreymuth/pulse/proto"
 )
 
+const (
+	// the xdg icon shown for the application (and its stream) by volume control applications
+	iconName = "audio-x-generic"
+
+	// PA_UPDATE_REPLACE - the properties given replace any already set, leaving the rest alone
+	propListUpdateReplace = 2
+
+	// how often a stalled output checks whether the connection to the server is still there
+	connectionCheckInterval = 100 * time.Millisecond
+
+	// how often the server is asked how the stream is playing, to find out whether it has run out of audio
+	underrunCheckInterval = 250 * time.Millisecond
+
+	// the underrun counter of a stream that hasn't started playing yet
+	underrunNotStarted = math.MaxUint64
+)
+
+var (
+	// ErrDisconnected is returned when the connection to the sound server has been lost (e.g.: it was restarted)
+	ErrDisconnected = errors.New("lost connection to the pulseaudio server")
+)
+
+// Config describes the playback stream to set up
+type Config struct {
+	AppName string
+	// the name of the sink to play to (blank = the default sink)
+	Sink          string
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	// how far behind the audio may be heard
+	Latency time.Duration
+	// the size of the server-side buffer, in samples (overrides Latency when set)
+	BufferSize int
+}
+
+// MediaInfo describes what is being played, for volume control applications to show
+type MediaInfo struct {
+	Title    string
+	Artist   string
+	Filename string
+}
+
+// Sink is a PulseAudio output device
+type Sink struct {
+	ID          string
+	Description string
+	IsDefault   bool
+}
+
 type Client struct {
 	pc    *pulse.Client
 	chmap proto.ChannelMap
 	strm  *pulse.PlaybackStream
 	ch    chan []byte
 	r     bytes.Buffer
+
+	underruns atomic.Int64
+	// mu guards the pausing of the stream and the timing info last had from the server
+	mu     sync.Mutex
+	paused bool
+	// the timing info from the server when it was last checked for underruns (nil = not yet, since the last pause)
+	timing      *proto.GetPlaybackLatencyReply
+	timingCheck time.Time
 }
 
-func New(appName string, sampleRate int, channels int, bitsPerSample int) (*Client, error) {
+func New(cfg Config) (*Client, error) {
 	pa := Client{}
 
-	switch channels {
+	switch cfg.Channels {
 	case 1:
 		pa.chmap = append(pa.chmap, proto.ChannelMono)
 	case 2:
@@ -32,25 +96,46 @@ func New(appName string, sampleRate int, channels int, bitsPerSample int) (*Clie
 	}
 
 	var r pulse.Reader
-	switch bitsPerSample {
+	switch cfg.BitsPerSample {
 	case 8:
 		r = pulse.NewReader(&pa, proto.FormatUint8)
 	case 16:
 		r = pulse.NewReader(&pa, proto.FormatInt16LE)
 	}
 
-	c, err := pulse.NewClient(pulse.ClientApplicationName(appName))
+	c, err := pulse.NewClient(pulse.ClientApplicationName(cfg.AppName), pulse.ClientApplicationIconName(iconName))
 	if err != nil {
 		return nil, err
 	}
 	pa.pc = c
 
+	// NOTE: the latency has to be set after the sample rate and the channels, as it's worked out from them
+	opts := []pulse.PlaybackOption{
+		pulse.PlaybackSampleRate(cfg.SampleRate),
+		pulse.PlaybackChannels(pa.chmap),
+		pulse.PlaybackMediaName(cfg.AppName),
+		pulse.PlaybackMediaIconName(iconName),
+		pulse.PlaybackRawOption(func(req *proto.CreatePlaybackStream) {
+			req.Properties["media.role"] = proto.PropListString("music")
+		}),
+	}
+	if cfg.BufferSize > 0 {
+		opts = append(opts, pulse.PlaybackBufferSize(cfg.BufferSize))
+	} else {
+		opts = append(opts, pulse.PlaybackLatency(cfg.Latency.Seconds()))
+	}
+	if cfg.Sink != "" {
+		sink, err := c.SinkByID(cfg.Sink)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		opts = append(opts, pulse.PlaybackSink(sink))
+	}
+
 	pa.ch = make(chan []byte)
 
-	strm, err := c.NewPlayback(r,
-		pulse.PlaybackSampleRate(sampleRate),
-		pulse.PlaybackLatency(0.1),
-		pulse.PlaybackChannels(pa.chmap))
+	strm, err := c.NewPlayback(r, opts...)
 	if err != nil {
 		c.Close()
 		close(pa.ch)
@@ -64,8 +149,134 @@ func New(appName string, sampleRate int, channels int, bitsPerSample int) (*Clie
 	return &pa, nil
 }
 
-func (pa *Client) Output(data []byte) {
-	pa.ch <- data
+// ListSinks returns the sinks that can be played to
+func ListSinks(appName string) ([]Sink, error) {
+	c, err := pulse.NewClient(pulse.ClientApplicationName(appName), pulse.ClientApplicationIconName(iconName))
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	sinks, err := c.ListSinks()
+	if err != nil {
+		return nil, err
+	}
+	var defaultID string
+	if def, err := c.DefaultSink(); err == nil {
+		defaultID = def.ID()
+	}
+
+	list := make([]Sink, len(sinks))
+	for i, s := range sinks {
+		list[i] = Sink{
+			ID:          s.ID(),
+			Description: s.Name(),
+			IsDefault:   s.ID() == defaultID,
+		}
+	}
+	return list, nil
+}
+
+// SetMediaInfo updates the properties of the stream describing what is being played
+func (pa *Client) SetMediaInfo(info MediaInfo) error {
+	props := proto.PropList{}
+	set := func(key, value string) {
+		if value != "" {
+			props[key] = proto.PropListString(value)
+		}
+	}
+	set("media.name", info.Title)
+	set("media.title", info.Title)
+	set("media.artist", info.Artist)
+	set("media.filename", info.Filename)
+	if len(props) == 0 {
+		return nil
+	}
+
+	return pa.pc.RawRequest(&proto.UpdatePlaybackStreamProplist{
+		StreamIndex: pa.strm.StreamIndex(),
+		Mode:        propListUpdateReplace,
+		Properties:  props,
+	}, nil)
+}
+
+// Output queues `data` up to be played, waiting until the server is ready for it.
+// It returns ErrDisconnected if the connection to the server is lost in the meantime.
+func (pa *Client) Output(ctx context.Context, data []byte) error {
+	pa.checkUnderruns()
+
+	check := time.NewTicker(connectionCheckInterval)
+	defer check.Stop()
+	for {
+		select {
+		case pa.ch <- data:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-check.C:
+			if pa.strm.Closed() {
+				return ErrDisconnected
+			}
+		}
+	}
+}
+
+// Underruns returns how many times the server has run out of audio to play
+func (pa *Client) Underruns() int {
+	return int(pa.underruns.Load())
+}
+
+// checkUnderruns counts the times the server has run out of audio to play since it was last checked.
+// The server keeps track of how long the stream has been playing for since it last ran out (or how long it's
+// been out for, while it still is), which starts over each time it runs out.
+// A lost connection is left for Output to notice.
+func (pa *Client) checkUnderruns() {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	now := time.Now()
+	if pa.paused || now.Sub(pa.timingCheck) < underrunCheckInterval {
+		return
+	}
+	pa.timingCheck = now
+
+	var timing proto.GetPlaybackLatencyReply
+	if err := pa.pc.RawRequest(&proto.GetPlaybackLatency{
+		StreamIndex: pa.strm.StreamIndex(),
+		Time: proto.Time{
+			Seconds:      uint32(now.Unix()),
+			Microseconds: uint32(now.Nanosecond() / 1000),
+		},
+	}, &timing); err != nil {
+		return
+	}
+
+	if last := pa.timing; last != nil && last.UnderrunFor == 0 {
+		switch {
+		case timing.UnderrunFor == 0 && timing.PlayingFor < last.PlayingFor:
+			// it ran out and got going again in between
+			pa.underruns.Add(1)
+		case timing.UnderrunFor > 0 && timing.UnderrunFor != underrunNotStarted:
+			// it's out right now (which isn't counted again once it gets going)
+			pa.underruns.Add(1)
+		}
+	}
+	pa.timing = &timing
+}
+
+func (pa *Client) Pause() {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	pa.paused = true
+	pa.strm.Pause()
+}
+
+func (pa *Client) Resume() {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	pa.paused = false
+	// the stream runs out while it's paused, which doesn't count
+	pa.timing = nil
+	pa.strm.Resume()
 }
 
 func (pa *Client) Read(p []byte) (int, error) {
@@ -14,7 +14,10 @@ type devicePriority int
 // the further down the list, the higher the priority
 const (
 	devicePriorityNone = devicePriority(iota)
+	devicePriorityHTTP
+	devicePriorityPipe
 	devicePriorityFile
+	devicePriorityNull // the default when there is no sound card
 	devicePriorityPulseAudio
 	devicePriorityWinmm
 	devicePriorityDirectSound
@@ -22,14 +25,14 @@ const (
 
 var (
 	// DefaultOutputDeviceName is the default device name
-	DefaultOutputDeviceName = "none"
+	DefaultOutputDeviceName = "null"
 
 	devicePriorityMap = make(map[string]devicePriority)
 )
 
 func calculateOptimalDefaultOutputDeviceName() string {
 	preferredPriority := devicePriority(0)
-	preferredName := "none"
+	preferredName := "null"
 	for name := range device.Map {
 		if priority, ok := devicePriorityMap[name]; ok && priority > preferredPriority {
 			preferredName = name
@@ -40,9 +43,14 @@ func calculateOptimalDefaultOutputDeviceName() string {
 	return preferredName
 }
 
-// CreateOutputDevice creates an output device based on the provided settings
-func CreateOutputDevice(settings deviceCommon.Settings) (device.Device, []feature.Feature, error) {
-	d, err := device.CreateOutputDevice(settings)
+// CreateOutputDevice creates an output device based on the provided settings, which get updated to the
+// sample rate and channel count nearest to them that the device can play (with a warning, if they had to change)
+func CreateOutputDevice(settings *deviceCommon.Settings) (device.Device, []feature.Feature, error) {
+	if err := device.Negotiate(settings); err != nil {
+		return nil, nil, err
+	}
+
+	d, err := device.CreateOutputDevice(*settings)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -53,6 +61,7 @@ func CreateOutputDevice(settings deviceCommon.Settings) (device.Device, []featur
 
 	var featureDisable []feature.Feature
 
+	// NOTE: a tee of devices is a sound card if any of them is one, so it keeps looping
 	kind := device.GetKind(d)
 	switch kind {
 	case deviceCommon.KindFile:
@@ -72,17 +81,20 @@ func Setup() {
 
 // DeviceInfo returns information about a device
 type DeviceInfo struct {
-	Priority int
-	Kind     deviceCommon.Kind
+	Priority     int
+	Kind         deviceCommon.Kind
+	Capabilities deviceCommon.Capabilities
 }
 
 func GetOutputDevices() map[string]DeviceInfo {
 	m := make(map[string]DeviceInfo)
 	for k, v := range devicePriorityMap {
 		if d, ok := device.Map[k]; ok {
+			caps, _ := device.GetCapabilities(k)
 			m[k] = DeviceInfo{
-				Priority: int(v),
-				Kind:     d.Kind,
+				Priority:     int(v),
+				Kind:         d.Kind,
+				Capabilities: caps,
 			}
 		}
 	}
@@ -91,6 +103,9 @@ func GetOutputDevices() map[string]DeviceInfo {
 
 func init() {
 	_ = devicePriorityNone // lint
+	devicePriorityMap["null"] = devicePriorityNull
+	devicePriorityMap["http"] = devicePriorityHTTP
+	devicePriorityMap["pipe"] = devicePriorityPipe
 	devicePriorityMap["file"] = devicePriorityFile
 	devicePriorityMap["pulseaudio"] = devicePriorityPulseAudio
 	devicePriorityMap["winmm"] = devicePriorityWinmm
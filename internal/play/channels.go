@@ -0,0 +1,41 @@
+package play
+
+import (
+	"github.com/gotracker/playback/mixing"
+	playbackOutput "github.com/gotracker/playback/output"
+	"github.com/gotracker/playback/player/machine"
+	"github.com/gotracker/playback/player/sampler"
+)
+
+// channelOutputMachine moves the output of the background (past note) voices out of the tracker
+// channels' data, so that the first channel data of each premix only holds the tracker channels
+type channelOutputMachine struct {
+	machine.MachineTicker
+	numChannels int
+}
+
+// Tick runs a single tick of the song
+func (m *channelOutputMachine) Tick(s *sampler.Sampler) error {
+	if s == nil {
+		return m.MachineTicker.Tick(s)
+	}
+
+	fs := *s
+	fs.OnGenerate = func(premix *playbackOutput.PremixData) {
+		if len(premix.Data) > 0 && len(premix.Data[0]) > m.numChannels {
+			channels, background := premix.Data[0][:m.numChannels], premix.Data[0][m.numChannels:]
+			data := make([]mixing.ChannelData, 0, len(premix.Data)+1)
+			data = append(data, channels, background)
+			premix.Data = append(data, premix.Data[1:]...)
+		}
+		if s.OnGenerate != nil {
+			s.OnGenerate(premix)
+		}
+	}
+	return m.MachineTicker.Tick(&fs)
+}
+
+// Unwrap returns the machine of the song
+func (m *channelOutputMachine) Unwrap() machine.MachineTicker {
+	return m.MachineTicker
+}
@@ -0,0 +1,95 @@
+package play
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time used by a Player to pace itself
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) (<-chan time.Time, func())
+}
+
+type wallClock struct{}
+
+// Now returns the current wall clock time
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTicker returns a channel that receives the wall clock time every `d`, as well as a function that stops it
+func (wallClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// WallClock is a Clock that follows the system wall clock
+var WallClock Clock = wallClock{}
+
+// ManualClock is a Clock that only moves when it is told to
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*manualTicker
+}
+
+type manualTicker struct {
+	ch       chan time.Time
+	interval time.Duration
+	next     time.Time
+	stopped  bool
+}
+
+// NewManualClock returns a ManualClock that starts at `start`
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{
+		now: start,
+	}
+}
+
+// Now returns the current time of the clock
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a channel that receives the clock time every `d` the clock is advanced by, as well as a function that stops it
+func (c *ManualClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &manualTicker{
+		ch:       make(chan time.Time, 1),
+		interval: d,
+		next:     c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, t)
+	return t.ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		t.stopped = true
+	}
+}
+
+// Advance moves the clock forward by `d`, firing any tickers that come due along the way
+// NOTE: like a time.Ticker, a ticker that has not been read from drops the ticks that come due in the meantime
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		if t.stopped || t.interval <= 0 {
+			continue
+		}
+		for !t.next.After(c.now) {
+			select {
+			case t.ch <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.interval)
+		}
+	}
+}
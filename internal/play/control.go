@@ -0,0 +1,48 @@
+package play
+
+import "errors"
+
+// Control is an enumeration of the transport controls that can be sent to a playing playlist
+type Control int
+
+const (
+	// ControlNone does nothing
+	ControlNone = Control(iota)
+	// ControlPauseToggle pauses a playing song or resumes a paused one
+	ControlPauseToggle
+	// ControlNext skips to the next entry in the playlist
+	ControlNext
+	// ControlPrevious returns to the previous entry in the playlist
+	ControlPrevious
+	// ControlQuit stops playback of the playlist
+	ControlQuit
+	// ControlSeekForward jumps forward to the start of the next order
+	ControlSeekForward
+	// ControlSeekBackward jumps back to the start of the previous order
+	ControlSeekBackward
+
+	// the channel controls carry their (0-based) channel number in their low bits
+	controlChannelMute = Control(0x10000)
+	controlChannelSolo = Control(0x20000)
+	controlChannelMask = Control(0x0ffff)
+)
+
+// ControlMuteChannel returns a control that toggles the mute of (0-based) channel `ch`
+func ControlMuteChannel(ch int) Control {
+	return controlChannelMute | (Control(ch) & controlChannelMask)
+}
+
+// ControlSoloChannel returns a control that toggles the solo of (0-based) channel `ch`
+func ControlSoloChannel(ch int) Control {
+	return controlChannelSolo | (Control(ch) & controlChannelMask)
+}
+
+// channel splits a channel control into the control and its channel number
+func (c Control) channel() (Control, int) {
+	return c &^ controlChannelMask, int(c & controlChannelMask)
+}
+
+var (
+	errPlaylistPrevious = errors.New("previous playlist entry requested")
+	errPlaylistQuit     = errors.New("playlist quit requested")
+)
@@ -0,0 +1,208 @@
+package play
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gotracker/playback/index"
+	"github.com/gotracker/playback/player/machine"
+	"github.com/gotracker/playback/player/machine/settings"
+	"github.com/gotracker/playback/song"
+	"github.com/gotracker/playback/tracing"
+)
+
+// maxEstimatedDuration is the longest a song is played for while estimating its duration.
+// A song that is still playing by then, without having come back around to somewhere it's already been
+// as many times as it's allowed to, is cut short.
+const maxEstimatedDuration = 2 * time.Hour
+
+// SongDuration is the estimated playing time of a song
+type SongDuration struct {
+	Total     time.Duration
+	Looping   bool           // the song loops forever, so Total only covers its first time through
+	Positions []PositionTime // the times at which playback arrives at each order, in the order they are played
+
+	tickDuration time.Duration // the length of the last tick of the song
+}
+
+// PositionTime is the time at which playback arrives at a position within a song
+type PositionTime struct {
+	Order int
+	Row   int
+	Time  time.Duration
+}
+
+// EstimateDuration returns how long a song plays for with the settings `us`,
+// found by running a silent (non-rendering) copy of it.
+// A song allowed to loop forever (a `us.SongLoopCount` below 0) which comes back around to somewhere
+// it's already been is Looping, otherwise it's played through `us.SongLoopCount`+1 times at most.
+func EstimateDuration(songData song.Data, us settings.UserSettings) (SongDuration, error) {
+	var tracer loopTracer
+	us.Tracer = &tracer
+
+	m, err := machine.NewMachine(songData, us)
+	if err != nil {
+		return SongDuration{}, err
+	}
+
+	mp, ok := asMachine[positioner](m)
+	if !ok {
+		return SongDuration{}, errors.New("machine does not report its position")
+	}
+
+	var d SongDuration
+	// how many times playback has been in each state at the start of a row - once one comes around again,
+	// the song is going to play the same way from there on, so it's started another pass through
+	seen := make(map[playbackState]int)
+	// the end of the first pass through the song, which is when it first comes back to an order it's already played
+	var (
+		firstPass      SongDuration
+		firstPassEnded bool
+	)
+	visitedOrders := make(map[int]struct{})
+	lastOrder := -1
+	for d.Total < maxEstimatedDuration {
+		pos := mp.GetPosition()
+		if int(pos.Order) != lastOrder {
+			if _, found := visitedOrders[int(pos.Order)]; found && !firstPassEnded {
+				firstPass = d
+				firstPassEnded = true
+			}
+			visitedOrders[int(pos.Order)] = struct{}{}
+			d.Positions = append(d.Positions, PositionTime{
+				Order: int(pos.Order),
+				Row:   int(pos.Row),
+				Time:  d.Total,
+			})
+			lastOrder = int(pos.Order)
+		}
+
+		if pos.Tick == 0 {
+			state := tracer.state(pos)
+			seen[state]++
+			if visits := seen[state]; visits > 1 {
+				if us.SongLoopCount < 0 {
+					if firstPassEnded {
+						d = firstPass
+					}
+					d.Looping = true
+					break
+				}
+				if visits > us.SongLoopCount+1 {
+					break
+				}
+			}
+		}
+
+		if err := m.Tick(nil); err != nil {
+			if errors.Is(err, song.ErrStopSong) {
+				break
+			}
+			return SongDuration{}, err
+		}
+		d.tickDuration = songData.GetTickDuration(tracer.bpm)
+		d.Total += d.tickDuration
+	}
+
+	return d, nil
+}
+
+// playbackState is where a song is at the start of a row, along with how far along its pattern loops are
+type playbackState struct {
+	order        index.Order
+	row          index.Row
+	patternLoops string
+}
+
+// patternLoop is the state of the pattern loop of a channel
+type patternLoop struct {
+	Start index.Row
+	End   index.Row
+	Total int
+	Count int
+}
+
+// loopTracer is a tracer that keeps track of the current BPM of a machine,
+// as well as the state of the pattern loops of its channels
+type loopTracer struct {
+	bpmTracer
+	patternLoops []patternLoop
+}
+
+func (t *loopTracer) state(pos machine.Position) playbackState {
+	return playbackState{
+		order:        pos.Order,
+		row:          pos.Row,
+		patternLoops: fmt.Sprint(t.patternLoops),
+	}
+}
+
+func (t *loopTracer) observe(op string, value any) {
+	t.bpmTracer.observe(op, value)
+	if op != "order" {
+		return
+	}
+	// the pattern loops are started over on each new order
+	for i := range t.patternLoops {
+		t.patternLoops[i].Total = 0
+		t.patternLoops[i].Count = 0
+	}
+}
+
+func (t *loopTracer) observeChannel(ch index.Channel, op string, value any) {
+	switch op {
+	case "patternLoopStart", "patternLoopEnd", "patternLoopTotal", "patternLoopCount":
+	default:
+		return
+	}
+
+	for int(ch) >= len(t.patternLoops) {
+		t.patternLoops = append(t.patternLoops, patternLoop{})
+	}
+	pl := &t.patternLoops[ch]
+	switch v := value.(type) {
+	case index.Row:
+		if op == "patternLoopStart" {
+			pl.Start = v
+		} else {
+			pl.End = v
+		}
+	case int:
+		if op == "patternLoopTotal" {
+			pl.Total = v
+		} else {
+			pl.Count = v
+		}
+	}
+}
+
+func (t *loopTracer) TraceValueChange(op string, prev, new any) {
+	t.observe(op, new)
+}
+func (t *loopTracer) TraceValueChangeWithComment(op string, prev, new any, commentFmt string, commentParams ...any) {
+	t.observe(op, new)
+}
+func (t *loopTracer) TraceChannelValueChange(ch index.Channel, op string, prev, new any) {
+	t.observeChannel(ch, op, new)
+}
+func (t *loopTracer) TraceChannelValueChangeWithComment(ch index.Channel, op string, prev, new any, commentFmt string, commentParams ...any) {
+	t.observeChannel(ch, op, new)
+}
+
+var _ tracing.TracerWithClose = (*loopTracer)(nil)
+
+// estimateDuration returns how long the song plays for, including its fade out
+func (s songSeeker) estimateDuration() (SongDuration, error) {
+	d, err := EstimateDuration(s.songData, s.us)
+	if err != nil || d.Looping || !s.fade.enabled() {
+		return d, err
+	}
+
+	if s.fade.ticks > 0 {
+		d.Total += time.Duration(s.fade.ticks) * d.tickDuration
+	} else {
+		d.Total += s.fade.duration
+	}
+	return d, nil
+}
@@ -0,0 +1,101 @@
+package play
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gotracker/playback/format"
+	"github.com/gotracker/playback/player/feature"
+	"github.com/gotracker/playback/player/machine/settings"
+)
+
+func estimateDuration(t *testing.T, filename string, loopCount int) SongDuration {
+	t.Helper()
+
+	songData, songFmt, err := format.Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var us settings.UserSettings
+	us.Reset()
+	if err := songFmt.ConvertFeaturesToSettings(&us, []feature.Feature{feature.SongLoop{Count: loopCount}}); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := EstimateDuration(songData, us)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestEstimateDurationMatchesRender(t *testing.T) {
+	const filename = "../../test/VibratoTypeChange.s3m"
+
+	d := estimateDuration(t, filename, 0)
+	if d.Looping {
+		t.Fatal("song should not loop")
+	}
+
+	// 16-bit stereo at 44100Hz
+	rendered := time.Duration(len(renderOffline(t, filename))/4) * time.Second / 44100
+	if diff := (d.Total - rendered).Abs(); diff > 10*time.Millisecond {
+		t.Fatalf("estimated %v, but rendered %v", d.Total, rendered)
+	}
+
+	if len(d.Positions) == 0 || d.Positions[0].Time != 0 {
+		t.Fatalf("unexpected positions: %v", d.Positions)
+	}
+}
+
+func TestEstimateDurationLoopForever(t *testing.T) {
+	t.Run("song ends", func(t *testing.T) {
+		// the song stops by itself, so it doesn't loop, even when allowed to
+		const filename = "../../test/weirdloop.s3m"
+
+		once := estimateDuration(t, filename, 0)
+		forever := estimateDuration(t, filename, -1)
+		if forever.Looping {
+			t.Fatal("song should not loop")
+		}
+		if forever.Total != once.Total {
+			t.Fatalf("expected %v, got %v", once.Total, forever.Total)
+		}
+	})
+
+	t.Run("song starts over", func(t *testing.T) {
+		// the song goes back to its start once it gets to the end, so it plays the same way over and over again
+		const filename = "../../test/ode_to_protracker.mod"
+
+		once := estimateDuration(t, filename, 0)
+		if once.Looping {
+			t.Fatal("song should stop once it comes back around")
+		}
+		forever := estimateDuration(t, filename, -1)
+		if !forever.Looping {
+			t.Fatal("song should loop forever")
+		}
+		// the first pass takes in the last tick before the song starts over, which a song that stops there doesn't play
+		if diff := (forever.Total - once.Total).Abs(); diff > forever.tickDuration {
+			t.Fatalf("expected a single pass of %v, got %v", once.Total, forever.Total)
+		}
+	})
+}
+
+func TestEstimateDurationLoopCount(t *testing.T) {
+	// the song goes back to its start once it gets to the end, so it plays through as many times as it's allowed to
+	const filename = "../../test/ode_to_protracker.mod"
+
+	forever := estimateDuration(t, filename, -1)
+	twice := estimateDuration(t, filename, 2)
+	if twice.Looping {
+		t.Fatal("song should not loop forever")
+	}
+	if diff := (twice.Total - 2*forever.Total).Abs(); diff > forever.tickDuration {
+		t.Fatalf("expected two passes of %v, got %v", forever.Total, twice.Total)
+	}
+	if len(twice.Positions) != 2*len(forever.Positions) {
+		t.Fatalf("expected %d positions, got %d", 2*len(forever.Positions), len(twice.Positions))
+	}
+}
@@ -0,0 +1,37 @@
+package play
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gotracker/gotracker/internal/dsp"
+)
+
+// newEffectsChain builds the chain of effects the mix is run through, from the chain definition file
+// (if there is one) followed by the effects named in the settings
+func newEffectsChain(renderSettings *Settings, channels, sampleRate int) (dsp.Chain, error) {
+	var specs []dsp.Spec
+	if renderSettings.DSPFile != "" {
+		f, err := os.Open(renderSettings.DSPFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		fileSpecs, err := dsp.ReadYAML(f)
+		if err != nil {
+			return nil, fmt.Errorf("could not read effects chain %q: %w", renderSettings.DSPFile, err)
+		}
+		specs = append(specs, fileSpecs...)
+	}
+
+	if renderSettings.DSP != "" {
+		flagSpecs, err := dsp.ParseSpecs(renderSettings.DSP)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, flagSpecs...)
+	}
+
+	return dsp.NewChain(specs, channels, sampleRate)
+}
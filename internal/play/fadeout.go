@@ -0,0 +1,120 @@
+package play
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gotracker/gotracker/internal/playlist"
+	"github.com/gotracker/playback/mixing/volume"
+	playbackOutput "github.com/gotracker/playback/output"
+	"github.com/gotracker/playback/player/machine"
+	"github.com/gotracker/playback/player/machine/settings"
+	"github.com/gotracker/playback/player/sampler"
+	"github.com/gotracker/playback/song"
+)
+
+// fadeout describes how a song fades out once it reaches its end
+type fadeout struct {
+	ticks    int           // length of the fade, in ticks
+	duration time.Duration // length of the fade, in time (only used when ticks is not set)
+}
+
+func newFadeout(f playlist.Fadeout) fadeout {
+	var fade fadeout
+	if ticks, ok := f.Length.Get(); ok {
+		fade.ticks = ticks
+	} else if t, ok := f.Time.Get(); ok {
+		fade.duration = t
+	}
+	return fade
+}
+
+func (f fadeout) enabled() bool {
+	return f.ticks > 0 || f.duration > 0
+}
+
+// length returns the length of the fade in the units `elapsed` is counted in -
+// ticks when the fade is tick-based, otherwise samples at `sampleRate`
+func (f fadeout) length(sampleRate int) int {
+	if f.ticks > 0 {
+		return f.ticks
+	}
+	return int(f.duration.Seconds() * float64(sampleRate))
+}
+
+func (f fadeout) elapsed(premix *playbackOutput.PremixData) int {
+	if f.ticks > 0 {
+		return 1
+	}
+	return premix.SamplesLen
+}
+
+// fadeoutMachine plays a song past its configured end while fading it out.
+// A silent copy of the song that still has the original end conditions is ticked
+// in lockstep with the audible one in order to find where the fade begins.
+type fadeoutMachine struct {
+	machine.MachineTicker
+	end     machine.MachineTicker
+	fade    fadeout
+	fading  bool
+	elapsed int
+}
+
+func newFadeoutMachine(create func(us settings.UserSettings) (machine.MachineTicker, error), us settings.UserSettings, fade fadeout) (machine.MachineTicker, error) {
+	end, err := create(us)
+	if err != nil {
+		return nil, err
+	}
+
+	// the audible machine gets enough extra song to fade out over
+	// NOTE: a loop count of 0 or 1 both stop the machine on the first loop
+	us.SongLoopCount = max(us.SongLoopCount, 1) + 1
+	us.PlayUntil.Order.Reset()
+	us.PlayUntil.Row.Reset()
+	m, err := create(us)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fadeoutMachine{
+		MachineTicker: m,
+		end:           end,
+		fade:          fade,
+	}, nil
+}
+
+// Tick runs a single tick of the song, attenuating its output if it is fading out
+func (f *fadeoutMachine) Tick(s *sampler.Sampler) error {
+	if !f.fading {
+		if err := f.end.Tick(nil); err != nil {
+			if !errors.Is(err, song.ErrStopSong) {
+				return err
+			}
+			f.fading = true
+		}
+	}
+
+	if !f.fading || s == nil {
+		return f.MachineTicker.Tick(s)
+	}
+
+	length := f.fade.length(s.SampleRate)
+	if f.elapsed >= length {
+		return song.ErrStopSong
+	}
+
+	fs := *s
+	fs.OnGenerate = func(premix *playbackOutput.PremixData) {
+		premix.MixerVolume *= volume.Volume(1 - float32(f.elapsed)/float32(length))
+		f.elapsed += f.fade.elapsed(premix)
+		if s.OnGenerate != nil {
+			s.OnGenerate(premix)
+		}
+	}
+	return f.MachineTicker.Tick(&fs)
+}
+
+// Unwrap returns the audible machine
+func (f *fadeoutMachine) Unwrap() machine.MachineTicker {
+	return f.MachineTicker
+}
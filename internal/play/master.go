@@ -0,0 +1,71 @@
+package play
+
+import (
+	"github.com/gotracker/playback/mixing"
+	playbackOutput "github.com/gotracker/playback/output"
+
+	"github.com/gotracker/gotracker/internal/dsp"
+	"github.com/gotracker/gotracker/internal/mastering"
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+	"github.com/gotracker/gotracker/internal/playlist"
+)
+
+// masterStage runs the mix through the mastering chain on its way out to the output device,
+// so that every kind of device gets the same mastered audio
+type masterStage struct {
+	channels int
+	defaults playlist.Master
+	m        *mastering.Master
+
+	lastUserdata any
+}
+
+func newMasterStage(channels, sampleRate int, renderSettings *Settings) *masterStage {
+	s := masterStage{
+		channels: channels,
+		m:        mastering.New(sampleRate),
+	}
+	s.defaults.DCFilter.Set(renderSettings.DCFilter)
+	s.defaults.Limiter.Set(renderSettings.Limiter)
+	s.defaults.LimiterCeiling.Set(renderSettings.LimiterCeiling)
+	s.defaults.LimiterRelease.Set(renderSettings.LimiterRelease)
+	s.defaults.SoftClip.Set(renderSettings.SoftClip)
+	return &s
+}
+
+// configure sets up the mastering of a playlist entry, with anything it doesn't set coming from the settings.
+// It returns whatever audio was held back by the stages being switched off, if there was any.
+func (s *masterStage) configure(master playlist.Master) *playbackOutput.PremixData {
+	master = master.Merge(s.defaults)
+	var settings mastering.Settings
+	settings.DCFilter, _ = master.DCFilter.Get()
+	settings.Limiter, _ = master.Limiter.Get()
+	settings.LimiterCeiling, _ = master.LimiterCeiling.Get()
+	settings.LimiterRelease, _ = master.LimiterRelease.Get()
+	settings.SoftClip, _ = master.SoftClip.Get()
+	return s.premix(s.m.Configure(settings))
+}
+
+// process returns the mastered version of `premix`
+func (s *masterStage) process(premix *playbackOutput.PremixData) *playbackOutput.PremixData {
+	if !s.m.IsEnabled() || premix.SamplesLen == 0 {
+		return premix
+	}
+
+	s.lastUserdata = deviceCommon.Userdata(premix)
+	data := s.m.Process(dsp.Flatten(s.channels, premix))
+	return deviceCommon.WithTrackerChannels(dsp.NewFlatPremix(s.channels, data, premix.Userdata), premix)
+}
+
+// flush returns whatever audio is still held back by the mastering chain, if there is any
+func (s *masterStage) flush() *playbackOutput.PremixData {
+	return s.premix(s.m.Flush())
+}
+
+// premix returns the held back audio `data` as premix data, if there is any
+func (s *masterStage) premix(data mixing.MixBuffer) *playbackOutput.PremixData {
+	if len(data) == 0 {
+		return nil
+	}
+	return deviceCommon.WithTrackerChannels(dsp.NewFlatPremix(s.channels, data, quietUserdata(s.lastUserdata)), nil)
+}
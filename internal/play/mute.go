@@ -0,0 +1,110 @@
+package play
+
+import (
+	"errors"
+
+	playbackOutput "github.com/gotracker/playback/output"
+	"github.com/gotracker/playback/player/machine"
+	"github.com/gotracker/playback/player/sampler"
+)
+
+// channelMutes keeps track of which tracker channels of a song are muted or soloed
+type channelMutes struct {
+	muted []bool
+	solo  []bool
+}
+
+// newChannelMutes returns the channel mutes of a song with `numChannels` channels,
+// with the (0-based) channels in `mute` muted and those in `solo` soloed
+func newChannelMutes(numChannels int, mute, solo []int) *channelMutes {
+	c := channelMutes{
+		muted: make([]bool, numChannels),
+		solo:  make([]bool, numChannels),
+	}
+
+	for _, ch := range mute {
+		if ch >= 0 && ch < numChannels {
+			c.muted[ch] = true
+		}
+	}
+
+	for _, ch := range solo {
+		if ch >= 0 && ch < numChannels {
+			c.solo[ch] = true
+		}
+	}
+
+	return &c
+}
+
+func (c *channelMutes) soloing() bool {
+	for _, solo := range c.solo {
+		if solo {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *channelMutes) isMuted(ch int) bool {
+	if c.soloing() {
+		return !c.solo[ch]
+	}
+	return c.muted[ch]
+}
+
+// toggleMute toggles the mute of channel `ch`, returning the new state of the channel
+func (c *channelMutes) toggleMute(ch int) (bool, error) {
+	if ch < 0 || ch >= len(c.muted) {
+		return false, errors.New("no such channel")
+	}
+	c.muted[ch] = !c.muted[ch]
+	return c.muted[ch], nil
+}
+
+// toggleSolo toggles the solo of channel `ch`, returning the new state of the channel
+func (c *channelMutes) toggleSolo(ch int) (bool, error) {
+	if ch < 0 || ch >= len(c.solo) {
+		return false, errors.New("no such channel")
+	}
+	c.solo[ch] = !c.solo[ch]
+	return c.solo[ch], nil
+}
+
+// mutingMachine silences the output of muted channels before it is mixed.
+// Only the output of the channels themselves is affected - the song plays on exactly as it would have
+// otherwise, and notes left playing in the background by New Note Actions can't be attributed to
+// a channel, so they are left alone.
+type mutingMachine struct {
+	machine.MachineTicker
+	mutes *channelMutes
+}
+
+// Tick runs a single tick of the song, silencing the muted channels
+func (m *mutingMachine) Tick(s *sampler.Sampler) error {
+	if s == nil {
+		return m.MachineTicker.Tick(s)
+	}
+
+	fs := *s
+	fs.OnGenerate = func(premix *playbackOutput.PremixData) {
+		if len(premix.Data) > 0 {
+			// the first channel data holds the output of the tracker channels, in order
+			for ch, cdata := range premix.Data[0] {
+				if ch < len(m.mutes.muted) && m.mutes.isMuted(ch) {
+					cdata.Volume = 0
+					premix.Data[0][ch] = cdata
+				}
+			}
+		}
+		if s.OnGenerate != nil {
+			s.OnGenerate(premix)
+		}
+	}
+	return m.MachineTicker.Tick(&fs)
+}
+
+// Unwrap returns the machine of the song
+func (m *mutingMachine) Unwrap() machine.MachineTicker {
+	return m.MachineTicker
+}
@@ -9,15 +9,19 @@ import (
 	"time"
 
 	progressBar "github.com/cheggaaa/pb"
-	"github.com/gotracker/playback"
 
 	"github.com/gotracker/gotracker/internal/feature"
 	"github.com/gotracker/gotracker/internal/logging"
 	"github.com/gotracker/gotracker/internal/output"
+	"github.com/gotracker/gotracker/internal/output/device"
 	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
 	"github.com/gotracker/gotracker/internal/playlist"
 	"github.com/gotracker/playback/format"
+	"github.com/gotracker/playback/format/it"
 	itFeature "github.com/gotracker/playback/format/it/feature"
+	"github.com/gotracker/playback/format/mod"
+	"github.com/gotracker/playback/format/s3m"
+	"github.com/gotracker/playback/format/xm"
 	playbackOutput "github.com/gotracker/playback/output"
 	playbackFeature "github.com/gotracker/playback/player/feature"
 	"github.com/gotracker/playback/player/machine"
@@ -28,33 +32,40 @@ import (
 	"github.com/gotracker/playback/tracing"
 )
 
-func Playlist(pl *playlist.Playlist, features []playbackFeature.Feature, settings *Settings, outCfg *deviceCommon.Settings, debugCfg *DebugSettings, logger logging.Log) (bool, error) {
+func Playlist(pl *playlist.Playlist, features []playbackFeature.Feature, settings *Settings, outCfg *deviceCommon.Settings, debugCfg *DebugSettings, logger logging.Log, controls <-chan Control) (bool, error) {
 	var (
-		play      playback.Playback
-		progress  *progressBar.ProgressBar
-		lastOrder int
+		progressMu sync.Mutex
+		progress   *progressBar.ProgressBar
 	)
 
+	outCfg.Messages = logging.Writer(logger)
 	outCfg.OnRowOutput = func(kind deviceCommon.Kind, premix *playbackOutput.PremixData) {
-		row := premix.Userdata.(*render.RowRender)
+		row, ok := deviceCommon.Userdata(premix).(*render.RowRender)
+		if !ok {
+			// e.g.: the details of a song about to start
+			return
+		}
 		switch kind {
 		case deviceCommon.KindSoundCard:
 			if row.RowText != nil {
 				logger.Printf("[%0.3d:%0.3d] %s\n", row.Order, row.Row, row.RowText.String())
 			}
 		case deviceCommon.KindFile:
-			if progress == nil {
-				progress = progressBar.StartNew(play.GetNumOrders())
-				lastOrder = row.Order
+			progressMu.Lock()
+			defer progressMu.Unlock()
+			if progress == nil || progress.IsFinished() {
+				return
 			}
-			if lastOrder != row.Order {
-				progress.Increment()
-				lastOrder = row.Order
+			rendered := time.Duration(premix.SamplesLen) * time.Second / time.Duration(outCfg.SamplesPerSecond)
+			current := progress.Get() + int64(rendered)
+			if progress.Total > 0 {
+				current = min(current, progress.Total)
 			}
+			progress.Set64(current)
 		}
 	}
 
-	waveOut, features, err := output.CreateOutputDevice(*outCfg)
+	waveOut, features, err := output.CreateOutputDevice(outCfg)
 	if err != nil {
 		return false, err
 	}
@@ -65,10 +76,11 @@ func Playlist(pl *playlist.Playlist, features []playbackFeature.Feature, setting
 		wg sync.WaitGroup
 	)
 	defer r.Close()
+	premixData := r.PremixData()
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := waveOut.Play(r.PremixData()); err != nil {
+		if err := waveOut.Play(premixData); err != nil {
 			switch {
 			case errors.Is(err, song.ErrStopSong):
 			case errors.Is(err, context.Canceled):
@@ -89,10 +101,14 @@ func Playlist(pl *playlist.Playlist, features []playbackFeature.Feature, setting
 
 	logger.Printf("Output device: %s\n", waveOut.Name())
 
-	err = r.renderSongs(pl, features, settings, outCfg, func(m machine.MachineTicker, outCfg *deviceCommon.Settings, out *sampler.Sampler, tickInterval time.Duration, tracer tracing.Tracer) error {
+	err = r.renderSongs(pl, features, settings, outCfg, func(m machine.MachineTicker, seeker Seeker, cur *preparedSong, outCfg *deviceCommon.Settings, out *sampler.Sampler, tickInterval time.Duration, tracer tracing.Tracer) error {
 		defer func() {
+			progressMu.Lock()
+			defer progressMu.Unlock()
 			if progress != nil {
-				progress.Set64(progress.Total)
+				if progress.Total > 0 {
+					progress.Set64(progress.Total)
+				}
 				progress.Finish()
 			}
 		}()
@@ -100,15 +116,26 @@ func Playlist(pl *playlist.Playlist, features []playbackFeature.Feature, setting
 		logger.Printf("Order Looping Enabled: %v\n", m.CanOrderLoop())
 		logger.Printf("Song: %s\n", m.GetName())
 
+		if device.GetKind(waveOut) == deviceCommon.KindFile {
+			progressMu.Lock()
+			progress = newSongProgress(cur.seeker, logger)
+			progressMu.Unlock()
+		}
+
 		p, err := NewPlayer(context.TODO(), tickInterval)
 		if err != nil {
 			return err
 		}
 
+		p.SetSeeker(seeker)
 		if err := p.Play(m, out, tracer); err != nil {
 			return err
 		}
 
+		if err := r.runControls(p, cur.seeker.mutes, waveOut, controls, logger); err != nil {
+			return err
+		}
+
 		if err := p.WaitUntilDone(); err != nil {
 			logger.Println()
 			logger.Println(err)
@@ -159,6 +186,20 @@ func (p *renderer) PremixData() <-chan *playbackOutput.PremixData {
 	return p.outBufs
 }
 
+// flush discards any premix data that has not yet been consumed by the output device
+func (p *renderer) flush() {
+	for {
+		select {
+		case _, ok := <-p.outBufs:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
 func (p *renderer) Close() error {
 	if p.outBufs != nil {
 		close(p.outBufs)
@@ -167,7 +208,143 @@ func (p *renderer) Close() error {
 	return nil
 }
 
-type playerCBFunc func(pb machine.MachineTicker, outCfg *deviceCommon.Settings, out *sampler.Sampler, tickInterval time.Duration, tracer tracing.Tracer) error
+// stop stops the player and discards whatever it had rendered ahead of the output device
+func (p *renderer) stop(player *Player) error {
+	err := player.Stop()
+	p.flush()
+	return err
+}
+
+// runControls services transport controls until the player is done
+func (p *renderer) runControls(player *Player, mutes *channelMutes, waveOut device.Device, controls <-chan Control, logger logging.Log) error {
+	paused := false
+	defer func() {
+		if paused {
+			_ = device.Resume(waveOut)
+		}
+	}()
+
+	for {
+		select {
+		case <-player.Done():
+			return nil
+		case c, ok := <-controls:
+			if !ok {
+				controls = nil
+				continue
+			}
+			switch c {
+			case ControlPauseToggle:
+				if paused {
+					if err := device.Resume(waveOut); err != nil {
+						return err
+					}
+					if err := player.Resume(); err != nil {
+						return err
+					}
+					logger.Println("[resumed]")
+				} else {
+					if !device.CanPause(waveOut) {
+						logger.Printf("[the %s device can't be paused]\n", waveOut.Name())
+						continue
+					}
+					if err := player.Pause(); err != nil {
+						return err
+					}
+					if err := device.Pause(waveOut); err != nil {
+						return err
+					}
+					logger.Println("[paused]")
+				}
+				paused = !paused
+			case ControlSeekForward, ControlSeekBackward:
+				delta := 1
+				if c == ControlSeekBackward {
+					delta = -1
+				}
+				if err := player.SeekOrders(delta); err != nil {
+					logger.Println(err)
+					continue
+				}
+				p.flush()
+			case ControlNext:
+				return p.stop(player)
+			case ControlPrevious:
+				if err := p.stop(player); err != nil {
+					return err
+				}
+				return errPlaylistPrevious
+			case ControlQuit:
+				if err := p.stop(player); err != nil {
+					return err
+				}
+				return errPlaylistQuit
+			default:
+				p.runChannelControl(c, player, mutes, logger)
+			}
+		}
+	}
+}
+
+// runChannelControl mutes or solos a channel of the playing song
+func (p *renderer) runChannelControl(c Control, player *Player, mutes *channelMutes, logger logging.Log) {
+	if mutes == nil {
+		return
+	}
+
+	var (
+		toggle func(ch int) (bool, error)
+		on     string
+		off    string
+	)
+	c, ch := c.channel()
+	switch c {
+	case controlChannelMute:
+		toggle, on, off = mutes.toggleMute, "muted", "unmuted"
+	case controlChannelSolo:
+		toggle, on, off = mutes.toggleSolo, "soloed", "unsoloed"
+	default:
+		return
+	}
+
+	// the mutes are used by the player while it plays, so they must be changed from within it
+	var state bool
+	if err := player.apply(func(machine.MachineTicker) error {
+		var err error
+		state, err = toggle(ch)
+		return err
+	}); err != nil {
+		logger.Printf("[channel %d: %v]\n", ch+1, err)
+		return
+	}
+
+	if state {
+		logger.Printf("[channel %d %s]\n", ch+1, on)
+	} else {
+		logger.Printf("[channel %d %s]\n", ch+1, off)
+	}
+}
+
+// newSongProgress returns a progress bar that counts up to the estimated duration of the song
+func newSongProgress(seeker *songSeeker, logger logging.Log) *progressBar.ProgressBar {
+	var total time.Duration
+	d, err := seeker.estimateDuration()
+	switch {
+	case err != nil:
+		logger.Printf("could not estimate song duration: %v\n", err)
+	case !d.Looping:
+		total = d.Total
+	}
+
+	progress := progressBar.New64(int64(total))
+	progress.SetUnits(progressBar.U_DURATION)
+	progress.Output = logging.Writer(logger)
+	// a song that loops forever has no end to count up to
+	progress.ShowPercent = total > 0
+	return progress.Start()
+}
+
+type playerCBFunc func(pb machine.MachineTicker, seeker Seeker, cur *preparedSong, outCfg *deviceCommon.Settings, out *sampler.Sampler, tickInterval time.Duration, tracer tracing.Tracer) error
 
 func (p *renderer) renderSongs(pl *playlist.Playlist, features []playbackFeature.Feature, renderSettings *Settings, outCfg *deviceCommon.Settings, startPlayingCB playerCBFunc) error {
 	tickInterval := time.Duration(5) * time.Millisecond
@@ -184,8 +361,13 @@ func (p *renderer) renderSongs(pl *playlist.Playlist, features []playbackFeature
 		canPossiblyLoop = (setting.Count != 0)
 	}
 
+	effects, err := newEffectsChain(renderSettings, outCfg.Channels, outCfg.SamplesPerSecond)
+	if err != nil {
+		return err
+	}
+	master := newMasterStage(outCfg.Channels, outCfg.SamplesPerSecond, renderSettings)
 	out := sampler.NewSampler(outCfg.SamplesPerSecond, outCfg.Channels, float32(outCfg.StereoSeparation)/100.0, func(premix *playbackOutput.PremixData) {
-		p.outBufs <- premix
+		p.outBufs <- master.process(effects.Process(premix))
 	})
 	if out == nil {
 		return errors.New("could not setup playback sampler")
@@ -204,76 +386,225 @@ func (p *renderer) renderSongs(pl *playlist.Playlist, features []playbackFeature
 
 	defer us.CloseTracing()
 
-playlistLoop:
-	for _, songIdx := range pl.GetPlaylist() {
-		entry := pl.GetSong(songIdx)
-		if entry == nil {
-			continue
-		}
-		songData, songFmt, err := format.Load(entry.Filepath, features...)
-		if err != nil {
-			return fmt.Errorf("could not create song state: %w", err)
-		}
-
-		cfg := features
-
-		cfg = append(cfg, playbackFeature.StartOrderAndRow{
-			Order: entry.Start.Order,
-			Row:   entry.Start.Row,
-		})
+	prepare := func(ls *loadedSong) (*preparedSong, error) {
+		return prepareSong(ls, pl.GetTransition(ls.entry), features, renderSettings, canPossiblyLoop, us)
+	}
 
-		endOrder, endOrderSet := entry.End.Order.Get()
-		endRow, endRowSet := entry.End.Row.Get()
-		if endOrderSet && endRowSet && endOrder >= 0 && endRow >= 0 {
-			cfg = append(cfg, playbackFeature.PlayUntilOrderAndRow{
-				Order: endOrder,
-				Row:   endRow,
-			})
+	var upcoming *preparedSong
+	playOrder := pl.GetPlaylist()
+	for i := 0; ; i++ {
+		if i >= len(playOrder) {
+			if !pl.IsLooping() || len(playOrder) == 0 {
+				break
+			}
+			playOrder = pl.GetPlaylist()
+			i = 0
 		}
 
-		if tempo, ok := entry.Tempo.Get(); ok {
-			cfg = append(cfg, playbackFeature.SetDefaultTempo{Tempo: tempo})
+		entry := pl.GetSong(playOrder[i])
+		if entry == nil {
+			continue
 		}
 
-		if bpm, ok := entry.BPM.Get(); ok {
-			cfg = append(cfg, playbackFeature.SetDefaultBPM{BPM: bpm})
+		cur := upcoming
+		upcoming = nil
+		if cur == nil || cur.entry != entry {
+			ls, err := loadSong(entry, features)
+			if err != nil {
+				return err
+			}
+			if cur, err = prepare(ls); err != nil {
+				return err
+			}
 		}
 
-		var loopCount int
-		if canPossiblyLoop {
-			if l, ok := entry.Loop.Count.Get(); ok {
-				loopCount = l
+		var (
+			playback machine.MachineTicker = cur.machine
+			seeker   Seeker                = cur.seeker
+			tr       *transition
+		)
+		if next := peekNextSong(pl, playOrder, i); next != nil {
+			if t := pl.GetTransition(entry); t.Mode.IsSet() {
+				tr = newTransition(t, loadSongAsync(next, features), prepare)
+				playback = tr.wrap(playback)
+				seeker = transitionSeeker{Seeker: seeker, t: tr}
 			}
 		}
-		cfg = append(cfg,
-			playbackFeature.SongLoop{Count: loopCount},
-			itFeature.LongChannelOutput{Enabled: renderSettings.ITLongChannelOutput},
-			itFeature.NewNoteActions{Enabled: renderSettings.ITEnableNNA})
-
-		us.Reset()
-		if songFmt != nil {
-			if err := songFmt.ConvertFeaturesToSettings(&us, cfg); err != nil {
-				return fmt.Errorf("could not configure playback settings: %w", err)
-			}
+
+		if tail := master.configure(pl.GetMaster(entry)); tail != nil {
+			p.outBufs <- tail
 		}
 
-		playback, err := machine.NewMachine(songData, us)
-		if err != nil {
-			return fmt.Errorf("could not create playback machine: %w", err)
+		p.outBufs <- &playbackOutput.PremixData{
+			Userdata: &deviceCommon.SongInfo{
+				Title:    playback.GetName(),
+				Artist:   entry.Artist,
+				Filepath: entry.Filepath,
+				Format:   cur.format,
+			},
 		}
 
-		if err = startPlayingCB(playback, outCfg, out, tickInterval, us.Tracer); err != nil {
+		if err := startPlayingCB(playback, seeker, cur, outCfg, out, tickInterval, us.Tracer); err != nil {
+			switch {
+			case errors.Is(err, errPlaylistQuit):
+				// the song was playing when we were asked to quit
+				p.playedAtLeastOneEntry = true
+				return nil
+			case errors.Is(err, errPlaylistPrevious):
+				// step back two, as the loop will step forward one
+				i = max(i-2, -1)
+			}
 			continue
 		}
 
 		pl.MarkPlayed(entry)
 
 		p.playedAtLeastOneEntry = true
+
+		if tr != nil {
+			var err error
+			if upcoming, err = tr.handover(); err != nil {
+				return err
+			}
+		}
 	}
 
-	if pl.IsLooping() {
-		goto playlistLoop
+	if tail := master.flush(); tail != nil {
+		p.outBufs <- tail
 	}
 
 	return nil
 }
+
+// peekNextSong returns the entry that will be played after the one at index `i` of `playOrder`, if it is known
+func peekNextSong(pl *playlist.Playlist, playOrder []int, i int) *playlist.Song {
+	switch {
+	case i+1 < len(playOrder):
+		return pl.GetSong(playOrder[i+1])
+	case pl.IsLooping() && !pl.IsRandomized() && len(playOrder) > 0:
+		// a randomized playlist is reshuffled when it loops, so we can't know what comes next
+		return pl.GetSong(playOrder[0])
+	default:
+		return nil
+	}
+}
+
+// loadedSong is a playlist entry whose song data has been loaded
+type loadedSong struct {
+	entry    *playlist.Song
+	songData song.Data
+	songFmt  format.Format
+}
+
+func loadSong(entry *playlist.Song, features []playbackFeature.Feature) (*loadedSong, error) {
+	songData, songFmt, err := format.Load(entry.Filepath, features...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create song state: %w", err)
+	}
+
+	return &loadedSong{
+		entry:    entry,
+		songData: songData,
+		songFmt:  songFmt,
+	}, nil
+}
+
+// formatName returns the name of the file format `f`
+func formatName(f format.Format) string {
+	switch f {
+	case it.IT:
+		return "IT"
+	case xm.XM:
+		return "XM"
+	case s3m.S3M:
+		return "S3M"
+	case mod.MOD:
+		return "MOD"
+	default:
+		return ""
+	}
+}
+
+// channelIndices converts 1-based channel numbers into 0-based channel indices
+func channelIndices(channels []int) []int {
+	indices := make([]int, 0, len(channels))
+	for _, ch := range channels {
+		indices = append(indices, ch-1)
+	}
+	return indices
+}
+
+// preparedSong is a playlist entry that is ready to be played
+type preparedSong struct {
+	entry   *playlist.Song
+	format  string
+	machine machine.MachineTicker
+	seeker  *songSeeker
+}
+
+func prepareSong(ls *loadedSong, tr playlist.Transition, features []playbackFeature.Feature, renderSettings *Settings, canPossiblyLoop bool, us settings.UserSettings) (*preparedSong, error) {
+	entry := ls.entry
+
+	cfg := features
+
+	cfg = append(cfg, playbackFeature.StartOrderAndRow{
+		Order: entry.Start.Order,
+		Row:   entry.Start.Row,
+	})
+
+	endOrder, endOrderSet := entry.End.Order.Get()
+	endRow, endRowSet := entry.End.Row.Get()
+	if endOrderSet && endRowSet && endOrder >= 0 && endRow >= 0 {
+		cfg = append(cfg, playbackFeature.PlayUntilOrderAndRow{
+			Order: endOrder,
+			Row:   endRow,
+		})
+	}
+
+	if tempo, ok := entry.Tempo.Get(); ok {
+		cfg = append(cfg, playbackFeature.SetDefaultTempo{Tempo: tempo})
+	}
+
+	if bpm, ok := entry.BPM.Get(); ok {
+		cfg = append(cfg, playbackFeature.SetDefaultBPM{BPM: bpm})
+	}
+
+	var loopCount int
+	if canPossiblyLoop {
+		if l, ok := entry.Loop.Count.Get(); ok {
+			loopCount = l
+		}
+	}
+	cfg = append(cfg,
+		playbackFeature.SongLoop{Count: loopCount},
+		itFeature.LongChannelOutput{Enabled: renderSettings.ITLongChannelOutput},
+		itFeature.NewNoteActions{Enabled: renderSettings.ITEnableNNA})
+
+	us.Reset()
+	if ls.songFmt != nil {
+		if err := ls.songFmt.ConvertFeaturesToSettings(&us, cfg); err != nil {
+			return nil, fmt.Errorf("could not configure playback settings: %w", err)
+		}
+	}
+
+	fade := newFadeout(entry.Fadeout)
+	if mode, _ := tr.Mode.Get(); mode == playlist.TransitionCrossfade {
+		// the song fades out over the length of the crossfade
+		length, _ := tr.Length.Get()
+		fade = fadeout{duration: length}
+	}
+
+	mutes := newChannelMutes(ls.songData.GetNumChannels(), channelIndices(entry.Mute), channelIndices(entry.Solo))
+	seeker := newSongSeeker(ls.songData, us, fade, mutes)
+	playback, err := seeker.newMachine(us)
+	if err != nil {
+		return nil, fmt.Errorf("could not create playback machine: %w", err)
+	}
+
+	return &preparedSong{
+		entry:   entry,
+		format:  formatName(ls.songFmt),
+		machine: playback,
+		seeker:  seeker,
+	}, nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/gotracker/playback/player/machine"
@@ -29,10 +28,18 @@ const (
 	playerOperationResume
 	playerOperationPause
 	playerOperationStop
+	playerOperationSeek
+	playerOperationApply
 )
 
+type seekFunc func(m machine.MachineTicker, seeker Seeker) (machine.MachineTicker, error)
+
+type applyFunc func(m machine.MachineTicker) error
+
 type playerOp struct {
 	op       playerOperation
+	seek     seekFunc
+	apply    applyFunc
 	response func(err error)
 }
 
@@ -46,17 +53,30 @@ type Player struct {
 	m              machine.MachineTicker
 	s              *sampler.Sampler
 	tracer         tracing.Tracer
-	ticker         *time.Ticker
+	seeker         Seeker
+	clock          Clock
+	offline        bool
 	tickerCh       <-chan time.Time
-	myTickerCh     chan time.Time
+	stopTicker     func()
 }
 
 // NewPlayer returns a new Player instance
 func NewPlayer(ctx context.Context, tickInterval time.Duration) (*Player, error) {
+	return NewPlayerWithClock(ctx, tickInterval, WallClock)
+}
+
+// NewPlayerWithClock returns a new Player instance that is paced by `clock`.
+// A `tickInterval` of 0 renders offline: the machine is ticked as fast as possible,
+// exactly one tick at a time, without ever consulting the clock.
+func NewPlayerWithClock(ctx context.Context, tickInterval time.Duration, clock Clock) (*Player, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
+	if clock == nil {
+		clock = WallClock
+	}
+
 	myCtx, cancel := context.WithCancelCause(ctx)
 
 	p := Player{
@@ -64,25 +84,19 @@ func NewPlayer(ctx context.Context, tickInterval time.Duration) (*Player, error)
 		cancel: cancel,
 		state:  playerStateIdle,
 		opCh:   make(chan playerOp, 1),
+		clock:  clock,
 	}
 
 	if tickInterval != time.Duration(0) {
-		p.ticker = time.NewTicker(tickInterval)
-		p.tickerCh = p.ticker.C
+		p.tickerCh, p.stopTicker = clock.NewTicker(tickInterval)
 	} else {
-		p.myTickerCh = make(chan time.Time, 1)
-		p.tickerCh = p.myTickerCh
-		p.myTickerCh <- time.Now()
+		p.offline = true
 	}
 
 	go func() {
 		defer func() {
-			close(p.opCh)
-
-			if p.ticker != nil {
-				p.ticker.Stop()
-			} else {
-				close(p.myTickerCh)
+			if p.stopTicker != nil {
+				p.stopTicker()
 			}
 		}()
 		err := p.runStateMachine()
@@ -108,22 +122,104 @@ func (p *Player) Play(m machine.MachineTicker, out *sampler.Sampler, tracer trac
 	return p.enqueueAndAwaitResponse(playerOperationPlay)
 }
 
-func (p *Player) enqueueAndAwaitResponse(op playerOperation) error {
-	var (
-		wg     sync.WaitGroup
-		result error
-	)
+// SetSeeker sets the seeker used to reposition the player
+// NOTE: this must be called prior to calling Play
+func (p *Player) SetSeeker(seeker Seeker) {
+	p.seeker = seeker
+}
+
+// Seek repositions the player to the start of the requested order and row
+func (p *Player) Seek(order, row int) error {
+	return p.enqueueSeekAndAwaitResponse(func(_ machine.MachineTicker, seeker Seeker) (machine.MachineTicker, error) {
+		return seeker.SeekPosition(order, row)
+	})
+}
+
+// SeekTime repositions the player to the row being played at time `t` from the start of the song
+func (p *Player) SeekTime(t time.Duration) error {
+	return p.enqueueSeekAndAwaitResponse(func(_ machine.MachineTicker, seeker Seeker) (machine.MachineTicker, error) {
+		return seeker.SeekTime(t)
+	})
+}
+
+// SeekOrders repositions the player to the start of the order `delta` orders away from the current one
+func (p *Player) SeekOrders(delta int) error {
+	return p.enqueueSeekAndAwaitResponse(func(m machine.MachineTicker, seeker Seeker) (machine.MachineTicker, error) {
+		mp, ok := asMachine[positioner](m)
+		if !ok {
+			return nil, errors.New("machine does not report its position")
+		}
+		order := int(mp.GetPosition().Order) + delta
+		order = max(min(order, m.GetNumOrders()-1), 0)
+		return seeker.SeekPosition(order, 0)
+	})
+}
+
+// Pause pauses a playing player
+func (p *Player) Pause() error {
+	return p.enqueueAndAwaitResponse(playerOperationPause)
+}
+
+// Resume resumes a paused player
+func (p *Player) Resume() error {
+	return p.enqueueAndAwaitResponse(playerOperationResume)
+}
+
+// Stop stops the player
+func (p *Player) Stop() error {
+	return p.enqueueAndAwaitResponse(playerOperationStop)
+}
+
+// Done returns a channel that is closed when the player is done
+func (p *Player) Done() <-chan struct{} {
+	return p.ctx.Done()
+}
 
-	wg.Add(1)
-	p.opCh <- playerOp{
+func (p *Player) enqueueAndAwaitResponse(op playerOperation) error {
+	return p.enqueueOpAndAwaitResponse(playerOp{
 		op: op,
-		response: func(err error) {
-			defer wg.Done()
-			result = err
-		},
+	})
+}
+
+func (p *Player) enqueueSeekAndAwaitResponse(seek seekFunc) error {
+	return p.enqueueOpAndAwaitResponse(playerOp{
+		op:   playerOperationSeek,
+		seek: seek,
+	})
+}
+
+// apply runs `apply` against the playing machine from within the player, so that it may safely modify it
+func (p *Player) apply(apply applyFunc) error {
+	return p.enqueueOpAndAwaitResponse(playerOp{
+		op:    playerOperationApply,
+		apply: apply,
+	})
+}
+
+func (p *Player) enqueueOpAndAwaitResponse(op playerOp) error {
+	result := make(chan error, 1)
+	op.response = func(err error) {
+		result <- err
+	}
+
+	select {
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	case p.opCh <- op:
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-p.ctx.Done():
+		// the state machine may have answered just before it shut down
+		select {
+		case err := <-result:
+			return err
+		default:
+			return p.ctx.Err()
+		}
 	}
-	wg.Wait()
-	return result
 }
 
 // WaitUntilDone waits until the player is done
@@ -172,7 +268,7 @@ func (p *Player) runStateIdle() error {
 	case op := <-p.opCh:
 		switch op.op {
 		case playerOperationPlay:
-			p.lastUpdateTime = time.Now()
+			p.lastUpdateTime = p.clock.Now()
 			p.state = playerStatePlaying
 			op.response(nil)
 		case playerOperationPause:
@@ -183,6 +279,8 @@ func (p *Player) runStateIdle() error {
 		case playerOperationStop:
 			op.response(nil)
 			return song.ErrStopSong
+		case playerOperationSeek, playerOperationApply:
+			op.response(errors.New("not playing"))
 		default:
 			op.response(fmt.Errorf("unhandled player operation while idle: %d", op.op))
 			return song.ErrStopSong
@@ -204,11 +302,15 @@ func (p *Player) runStatePaused() error {
 			op.response(nil)
 		case playerOperationResume:
 			op.response(nil)
-			p.lastUpdateTime = time.Now()
+			p.lastUpdateTime = p.clock.Now()
 			p.state = playerStatePlaying
 		case playerOperationStop:
 			op.response(nil)
 			return song.ErrStopSong
+		case playerOperationSeek:
+			op.response(p.runSeek(op.seek))
+		case playerOperationApply:
+			op.response(op.apply(p.m))
 		default:
 			op.response(fmt.Errorf("unhandled player operation while paused: %d", op.op))
 			return song.ErrStopSong
@@ -218,42 +320,78 @@ func (p *Player) runStatePaused() error {
 }
 
 func (p *Player) runStatePlaying() error {
+	if p.offline {
+		// service any pending operation, then run exactly one tick
+		select {
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		case op := <-p.opCh:
+			if stateChanged, err := p.runPlayingOp(op); stateChanged || err != nil {
+				return err
+			}
+		default:
+		}
+		return p.tick()
+	}
+
 	select {
 	case <-p.ctx.Done():
 		return p.ctx.Err()
 	case op := <-p.opCh:
-		switch op.op {
-		case playerOperationPlay:
-			op.response(errors.New("already playing"))
-		case playerOperationPause:
-			op.response(nil)
-			p.state = playerStatePaused
-			return nil
-		case playerOperationResume:
-			// eat it if we're already playing.
-			op.response(nil)
-		case playerOperationStop:
-			op.response(nil)
-			return song.ErrStopSong
-		default:
-			op.response(fmt.Errorf("unhandled player operation while playing: %d", op.op))
-			return song.ErrStopSong
+		if stateChanged, err := p.runPlayingOp(op); stateChanged || err != nil {
+			return err
 		}
 	case <-p.tickerCh:
-		if p.ticker == nil {
-			// give ourselves something to hit the next time through
-			p.myTickerCh <- time.Now()
-		}
 	}
 
 	// run our update
-	now := time.Now()
+	now := p.clock.Now()
 	delta := now.Sub(p.lastUpdateTime)
 	err := p.update(delta)
 	p.lastUpdateTime = now
 	return err
 }
 
+// runPlayingOp runs an operation received while playing, returning true if the player is no longer playing
+func (p *Player) runPlayingOp(op playerOp) (bool, error) {
+	switch op.op {
+	case playerOperationPlay:
+		op.response(errors.New("already playing"))
+	case playerOperationPause:
+		op.response(nil)
+		p.state = playerStatePaused
+		return true, nil
+	case playerOperationResume:
+		// eat it if we're already playing.
+		op.response(nil)
+	case playerOperationStop:
+		op.response(nil)
+		return true, song.ErrStopSong
+	case playerOperationSeek:
+		op.response(p.runSeek(op.seek))
+	case playerOperationApply:
+		op.response(op.apply(p.m))
+	default:
+		op.response(fmt.Errorf("unhandled player operation while playing: %d", op.op))
+		return true, song.ErrStopSong
+	}
+	return false, nil
+}
+
+func (p *Player) runSeek(seek seekFunc) error {
+	if p.seeker == nil {
+		return errors.New("player does not support seeking")
+	}
+
+	m, err := seek(p.m, p.seeker)
+	if err != nil {
+		return err
+	}
+
+	p.m = m
+	return nil
+}
+
 func (p *Player) update(delta time.Duration) error {
 	remaining := delta
 
@@ -261,30 +399,35 @@ func (p *Player) update(delta time.Duration) error {
 	firstSet := false
 
 	for !firstSet || remaining < first {
-		if err := func() error {
-			defer func() {
-				if p.tracer != nil {
-					p.tracer.OutputTraces()
-				}
-			}()
-
-			start := time.Now()
-			if err := p.m.Tick(p.s); err != nil {
-				return err
-			}
-			dur := time.Since(start)
-
-			if !firstSet {
-				firstSet = true
-				first = dur
-			}
+		if len(p.opCh) > 0 {
+			// let the state machine service the pending operation
+			break
+		}
 
-			remaining -= dur
-			return nil
-		}(); err != nil {
+		start := p.clock.Now()
+		if err := p.tick(); err != nil {
 			return err
 		}
+		dur := p.clock.Now().Sub(start)
+
+		if !firstSet {
+			firstSet = true
+			first = dur
+		}
+
+		remaining -= dur
 	}
 
 	return nil
 }
+
+// tick runs a single tick of the machine
+func (p *Player) tick() error {
+	defer func() {
+		if p.tracer != nil {
+			p.tracer.OutputTraces()
+		}
+	}()
+
+	return p.m.Tick(p.s)
+}
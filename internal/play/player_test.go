@@ -0,0 +1,201 @@
+package play
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/gotracker/playback/format"
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/mixing/sampling"
+	playbackOutput "github.com/gotracker/playback/output"
+	"github.com/gotracker/playback/player/feature"
+	"github.com/gotracker/playback/player/machine"
+	"github.com/gotracker/playback/player/machine/settings"
+	"github.com/gotracker/playback/player/sampler"
+	"github.com/gotracker/playback/song"
+)
+
+type fakeMachine struct {
+	ticks    int
+	maxTicks int
+	ticked   chan int
+}
+
+func (m *fakeMachine) GetNumOrders() int {
+	return 1
+}
+
+func (m *fakeMachine) CanOrderLoop() bool {
+	return false
+}
+
+func (m *fakeMachine) GetName() string {
+	return "fake"
+}
+
+func (m *fakeMachine) Tick(s *sampler.Sampler) error {
+	if m.ticks >= m.maxTicks {
+		return song.ErrStopSong
+	}
+	m.ticks++
+	if m.ticked != nil {
+		m.ticked <- m.ticks
+	}
+	return nil
+}
+
+func TestPlayerOffline(t *testing.T) {
+	p, err := NewPlayer(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &fakeMachine{maxTicks: 1000}
+	if err := p.Play(m, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.WaitUntilDone(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.ticks != m.maxTicks {
+		t.Fatalf("expected %d ticks, got %d", m.maxTicks, m.ticks)
+	}
+}
+
+func TestPlayerManualClock(t *testing.T) {
+	const tickInterval = 10 * time.Millisecond
+
+	clock := NewManualClock(time.Unix(0, 0))
+	p, err := NewPlayerWithClock(context.Background(), tickInterval, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &fakeMachine{maxTicks: 3, ticked: make(chan int, 1)}
+	if err := p.Play(m, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= m.maxTicks; i++ {
+		clock.Advance(tickInterval)
+		if tick := <-m.ticked; tick != i {
+			t.Fatalf("expected tick %d, got %d", i, tick)
+		}
+	}
+
+	if err := p.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(tickInterval)
+	select {
+	case tick := <-m.ticked:
+		t.Fatalf("unexpected tick %d while paused", tick)
+	case <-p.Done():
+		t.Fatal("player stopped while paused")
+	default:
+	}
+
+	if err := p.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(tickInterval)
+
+	if err := p.WaitUntilDone(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlayerStop(t *testing.T) {
+	p, err := NewPlayer(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &fakeMachine{maxTicks: 100, ticked: make(chan int)}
+	if err := p.Play(m, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	<-m.ticked
+	go func() {
+		// keep the machine going until the player gets around to stopping
+		for range m.ticked {
+		}
+	}()
+
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.WaitUntilDone(); err != nil {
+		t.Fatal(err)
+	}
+	close(m.ticked)
+
+	if m.ticks >= m.maxTicks {
+		t.Fatal("player played to the end of the song instead of stopping")
+	}
+}
+
+func renderOffline(t *testing.T, filename string) []byte {
+	t.Helper()
+
+	songData, songFmt, err := format.Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var us settings.UserSettings
+	us.Reset()
+	if err := songFmt.ConvertFeaturesToSettings(&us, []feature.Feature{feature.SongLoop{Count: 0}}); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := machine.NewMachine(songData, us)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		out   bytes.Buffer
+		mixer mixing.Mixer
+	)
+	mixer.Channels = 2
+	s := sampler.NewSampler(44100, mixer.Channels, 0.5, func(premix *playbackOutput.PremixData) {
+		out.Write(mixer.Flatten(premix.SamplesLen, premix.Data, premix.MixerVolume, sampling.Format16BitLESigned))
+	})
+
+	p, err := NewPlayer(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Play(m, s, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.WaitUntilDone(); err != nil {
+		t.Fatal(err)
+	}
+
+	return out.Bytes()
+}
+
+func TestPlayerOfflineDeterministic(t *testing.T) {
+	const filename = "../../test/VibratoTypeChange.s3m"
+
+	first := renderOffline(t, filename)
+	if len(first) == 0 {
+		t.Fatal("nothing was rendered")
+	}
+
+	second := renderOffline(t, filename)
+	if sha256.Sum256(first) != sha256.Sum256(second) {
+		t.Fatal("renders of the same song differ")
+	}
+}
@@ -0,0 +1,246 @@
+package play
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/gotracker/playback/index"
+	"github.com/gotracker/playback/player/machine"
+	"github.com/gotracker/playback/player/machine/settings"
+	"github.com/gotracker/playback/song"
+	"github.com/gotracker/playback/tracing"
+)
+
+var (
+	// ErrSeekOutOfRange is returned when a seek target lies outside of the song
+	ErrSeekOutOfRange = errors.New("seek target out of range")
+)
+
+// Seeker creates machines that start at a specific location within a song
+type Seeker interface {
+	SeekPosition(order, row int) (machine.MachineTicker, error)
+	SeekTime(t time.Duration) (machine.MachineTicker, error)
+}
+
+type positioner interface {
+	GetPosition() machine.Position
+}
+
+// songSeeker seeks by building a fresh machine for the song, as if it were started at the target location
+// with the tempo, BPM and global volume the song has by the time it gets there
+type songSeeker struct {
+	songData song.Data
+	us       settings.UserSettings
+	fade     fadeout
+	mutes    *channelMutes
+
+	// the global volume to start the machine with (nil = the song's own)
+	globalVolume any
+}
+
+func newSongSeeker(songData song.Data, us settings.UserSettings, fade fadeout, mutes *channelMutes) *songSeeker {
+	return &songSeeker{
+		songData: songData,
+		us:       us,
+		fade:     fade,
+		mutes:    mutes,
+	}
+}
+
+// newMachine returns a machine for the song, fading it out at its end when so configured
+func (s songSeeker) newMachine(us settings.UserSettings) (machine.MachineTicker, error) {
+	if s.fade.enabled() && us.SongLoopCount >= 0 {
+		return newFadeoutMachine(s.createMachine, us, s.fade)
+	}
+	return s.createMachine(us)
+}
+
+// createMachine returns a machine for the song which silences the channels that are muted
+func (s songSeeker) createMachine(us settings.UserSettings) (machine.MachineTicker, error) {
+	pm, err := machine.NewMachine(s.songData, us)
+	if err != nil {
+		return nil, err
+	}
+	if s.globalVolume != nil {
+		if err := setGlobalVolume(pm, s.globalVolume); err != nil {
+			return nil, err
+		}
+	}
+
+	m := &channelOutputMachine{
+		MachineTicker: pm,
+		numChannels:   s.songData.GetNumChannels(),
+	}
+
+	if s.mutes == nil {
+		return m, nil
+	}
+
+	return &mutingMachine{
+		MachineTicker: m,
+		mutes:         s.mutes,
+	}, nil
+}
+
+// SeekPosition returns a machine that starts at the provided order and row
+func (s songSeeker) SeekPosition(order, row int) (machine.MachineTicker, error) {
+	if order < 0 || order >= len(s.songData.GetOrderList()) {
+		return nil, fmt.Errorf("%w: order %d", ErrSeekOutOfRange, order)
+	}
+	if row < 0 {
+		return nil, fmt.Errorf("%w: row %d", ErrSeekOutOfRange, row)
+	}
+
+	us := s.us
+	us.Start.Order.Set(index.Order(order))
+	us.Start.Row.Set(index.Row(row))
+
+	// the song is only followed through once, as that's as far as it has to go to get anywhere it can
+	walkUS := s.us
+	walkUS.SongLoopCount = 0
+	st, err := s.walk(walkUS, func(pos machine.Position, elapsed time.Duration) bool {
+		return (int(pos.Order) == order && int(pos.Row) == row && pos.Tick == 0) || elapsed >= maxEstimatedDuration
+	})
+	switch {
+	case errors.Is(err, song.ErrStopSong):
+		// the song never gets there by itself, so it starts there as it is at its start
+	case err != nil:
+		return nil, err
+	case int(st.pos.Order) == order && int(st.pos.Row) == row:
+		us.Start.Tempo = st.tempo
+		us.Start.BPM = st.bpm
+		s.globalVolume = st.globalVolume
+	}
+	return s.newMachine(us)
+}
+
+// SeekTime returns a machine that starts at the row being played at time `t`
+func (s songSeeker) SeekTime(t time.Duration) (machine.MachineTicker, error) {
+	st, err := s.walk(s.us, func(pos machine.Position, elapsed time.Duration) bool {
+		return elapsed >= t
+	})
+	if err != nil {
+		if errors.Is(err, song.ErrStopSong) {
+			return nil, fmt.Errorf("%w: %v", ErrSeekOutOfRange, t)
+		}
+		return nil, err
+	}
+
+	return s.SeekPosition(int(st.pos.Order), int(st.pos.Row))
+}
+
+// seekState is where a song has got to, along with the global settings it has there
+type seekState struct {
+	pos          machine.Position
+	tempo        int
+	bpm          int
+	globalVolume any
+}
+
+// walk runs a silent (non-rendering) copy of the song with the settings `us` until `arrived` says that it's
+// got where it's going, returning song.ErrStopSong if the song ends first
+func (s songSeeker) walk(us settings.UserSettings, arrived func(pos machine.Position, elapsed time.Duration) bool) (seekState, error) {
+	var tracer globalsTracer
+	us.Tracer = &tracer
+
+	m, err := machine.NewMachine(s.songData, us)
+	if err != nil {
+		return seekState{}, err
+	}
+
+	mp, ok := asMachine[positioner](m)
+	if !ok {
+		return seekState{}, errors.New("machine does not report its position")
+	}
+
+	var elapsed time.Duration
+	for !arrived(mp.GetPosition(), elapsed) {
+		if err := m.Tick(nil); err != nil {
+			return seekState{}, err
+		}
+		elapsed += s.songData.GetTickDuration(tracer.bpm)
+	}
+
+	return seekState{
+		pos:          mp.GetPosition(),
+		tempo:        tracer.tempo,
+		bpm:          tracer.bpm,
+		globalVolume: tracer.globalVolume,
+	}, nil
+}
+
+// setGlobalVolume sets the global volume of the machine `m` to `gv`, which has to be of the type the machine uses
+func setGlobalVolume(m machine.MachineTicker, gv any) error {
+	set := reflect.ValueOf(m).MethodByName("SetGlobalVolume")
+	if !set.IsValid() || set.Type().NumIn() != 1 || set.Type().NumOut() != 1 || !reflect.TypeOf(gv).AssignableTo(set.Type().In(0)) {
+		return fmt.Errorf("machine does not take a global volume of type %T", gv)
+	}
+	if err, _ := set.Call([]reflect.Value{reflect.ValueOf(gv)})[0].Interface().(error); err != nil {
+		return err
+	}
+	return nil
+}
+
+// globalsTracer is a tracer that keeps track of the current tempo, BPM and global volume of a machine
+type globalsTracer struct {
+	bpmTracer
+	tempo        int
+	globalVolume any
+}
+
+func (t *globalsTracer) observe(op string, value any) {
+	t.bpmTracer.observe(op, value)
+	switch op {
+	case "tempo":
+		if tempo, ok := value.(int); ok {
+			t.tempo = tempo
+		}
+	case "gv":
+		t.globalVolume = value
+	}
+}
+
+func (t *globalsTracer) TraceValueChange(op string, prev, new any) {
+	t.observe(op, new)
+}
+func (t *globalsTracer) TraceValueChangeWithComment(op string, prev, new any, commentFmt string, commentParams ...any) {
+	t.observe(op, new)
+}
+
+var _ tracing.TracerWithClose = (*globalsTracer)(nil)
+
+// bpmTracer is a tracer that only keeps track of the current BPM of a machine
+type bpmTracer struct {
+	bpm int
+}
+
+func (t *bpmTracer) observe(op string, value any) {
+	if op != "bpm" {
+		return
+	}
+	if bpm, ok := value.(int); ok {
+		t.bpm = bpm
+	}
+}
+
+func (*bpmTracer) OutputTraces()                                                {}
+func (*bpmTracer) SetTracingTick(order index.Order, row index.Row, tick int)    {}
+func (*bpmTracer) Trace(op string)                                              {}
+func (*bpmTracer) TraceWithComment(op, commentFmt string, commentParams ...any) {}
+func (t *bpmTracer) TraceValueChange(op string, prev, new any) {
+	t.observe(op, new)
+}
+func (t *bpmTracer) TraceValueChangeWithComment(op string, prev, new any, commentFmt string, commentParams ...any) {
+	t.observe(op, new)
+}
+func (*bpmTracer) TraceChannel(ch index.Channel, op string) {}
+func (*bpmTracer) TraceChannelWithComment(ch index.Channel, op, commentFmt string, commentParams ...any) {
+}
+func (*bpmTracer) TraceChannelValueChange(ch index.Channel, op string, prev, new any) {}
+func (*bpmTracer) TraceChannelValueChangeWithComment(ch index.Channel, op string, prev, new any, commentFmt string, commentParams ...any) {
+}
+func (*bpmTracer) Close() error { return nil }
+
+var _ tracing.TracerWithClose = (*bpmTracer)(nil)
@@ -0,0 +1,71 @@
+package play
+
+import (
+	"testing"
+
+	"github.com/gotracker/playback/format"
+	"github.com/gotracker/playback/player/feature"
+	"github.com/gotracker/playback/player/machine"
+	"github.com/gotracker/playback/player/machine/settings"
+)
+
+func TestSeekPositionKeepsGlobals(t *testing.T) {
+	for _, filename := range []string{
+		"../../test/celestial_fantasia.s3m",
+		"../../test/fq-hypno.it",
+		"../../test/mothershipftw-stroberev2.xm",
+		"../../test/zeta_force_level_2.xm",
+		"../../test/ode_to_protracker.mod",
+	} {
+		t.Run(filename, func(t *testing.T) {
+			songData, songFmt, err := format.Load(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var us settings.UserSettings
+			us.Reset()
+			if err := songFmt.ConvertFeaturesToSettings(&us, []feature.Feature{feature.SongLoop{Count: 0}}); err != nil {
+				t.Fatal(err)
+			}
+
+			// play the song through, checking the globals of a seek to the start of each order against it
+			var played globalsTracer
+			playUS := us
+			playUS.Tracer = &played
+			m, err := machine.NewMachine(songData, playUS)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mp, _ := asMachine[positioner](m)
+
+			lastOrder := -1
+			for {
+				pos := mp.GetPosition()
+				if err := m.Tick(nil); err != nil {
+					break
+				}
+				if int(pos.Order) == lastOrder || pos.Row != 0 || pos.Tick != 0 {
+					continue
+				}
+				lastOrder = int(pos.Order)
+
+				var seeked globalsTracer
+				seekUS := us
+				seekUS.Tracer = &seeked
+				sm, err := newSongSeeker(songData, seekUS, fadeout{}, nil).SeekPosition(int(pos.Order), int(pos.Row))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := sm.Tick(nil); err != nil {
+					t.Fatal(err)
+				}
+
+				if seeked.tempo != played.tempo || seeked.bpm != played.bpm || seeked.globalVolume != played.globalVolume {
+					t.Fatalf("seek to order %d: got tempo %d, bpm %d, global volume %v, expected tempo %d, bpm %d, global volume %v",
+						pos.Order, seeked.tempo, seeked.bpm, seeked.globalVolume, played.tempo, played.bpm, played.globalVolume)
+				}
+			}
+		})
+	}
+}
@@ -1,9 +1,18 @@
 package play
 
+import "time"
+
 type Settings struct {
-	NumPremixBuffers    int  `pflag:"num-buffers" env:"num_buffers" usage:"number of premixed buffers"`
-	ITLongChannelOutput bool `pflag:"it-long" env:"it_long" usage:"enable Impulse Tracker long channel display"`
-	ITEnableNNA         bool `pflag:"it-enable-nna" env:"it_enable_nna" usage:"enable Impulse Tracker New Note Actions"`
+	NumPremixBuffers    int           `pflag:"num-buffers" env:"num_buffers" usage:"number of premixed buffers"`
+	ITLongChannelOutput bool          `pflag:"it-long" env:"it_long" usage:"enable Impulse Tracker long channel display"`
+	ITEnableNNA         bool          `pflag:"it-enable-nna" env:"it_enable_nna" usage:"enable Impulse Tracker New Note Actions"`
+	DCFilter            bool          `pflag:"dc-filter" env:"dc_filter" usage:"remove any DC offset from the mix"`
+	Limiter             bool          `pflag:"limiter" env:"limiter" usage:"run the mix through a look-ahead peak limiter"`
+	LimiterCeiling      float64       `pflag:"limiter-ceiling" env:"limiter_ceiling" usage:"highest level the limiter lets through (in dBFS)"`
+	LimiterRelease      time.Duration `pflag:"limiter-release" env:"limiter_release" usage:"how long the limiter takes to let go after a peak"`
+	SoftClip            bool          `pflag:"soft-clip" env:"soft_clip" usage:"round off peaks in the mix instead of clipping them"`
+	DSP                 string        `pflag:"dsp" env:"dsp" usage:"chain of effects to run the mix through (eq, reverb, chorus, widen), e.g.: eq:type=lowshelf:freq=100:gain=3,reverb:mix=0.2"`
+	DSPFile             string        `pflag:"dsp-file" env:"dsp_file" usage:"YAML file defining a chain of effects to run the mix through (before any given by --dsp)"`
 }
 
 type DebugSettings struct {
@@ -0,0 +1,74 @@
+package play
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gotracker/gotracker/internal/logging"
+	deviceCommon "github.com/gotracker/gotracker/internal/output/device/common"
+	"github.com/gotracker/gotracker/internal/playlist"
+)
+
+// the stems are made from the tracker channels, which have to make it through whatever is done to the mix
+func TestStemsWithProcessedMix(t *testing.T) {
+	const (
+		filename = "../../test/OxxMemory.s3m"
+		channels = 4
+	)
+
+	for _, tc := range []struct {
+		name     string
+		settings Settings
+	}{
+		{"limiter", Settings{Limiter: true, LimiterCeiling: -6, LimiterRelease: 50 * time.Millisecond}},
+		{"dsp", Settings{DSP: "widen"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			pl := playlist.New()
+			pl.Add(playlist.Song{
+				Filepath: filename,
+				Loop: playlist.Loop{
+					Count: playlist.NewLoopCount(0),
+				},
+			})
+
+			settings := tc.settings
+			settings.NumPremixBuffers = 64
+			outCfg := deviceCommon.Settings{
+				Name:             "file",
+				Channels:         2,
+				SamplesPerSecond: 44100,
+				BitsPerSample:    16,
+				StereoSeparation: 50,
+				Filepath:         filepath.Join(dir, "out.wav"),
+				Stems:            true,
+				StemsMaster:      true,
+			}
+			logger := &logging.Squelchable{Squelch: true, Output: io.Discard}
+			if _, err := Playlist(pl, nil, &settings, &outCfg, &DebugSettings{}, logger, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			master, err := os.Stat(outCfg.Filepath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for ch := 1; ch <= channels; ch++ {
+				stem, err := os.Stat(filepath.Join(dir, fmt.Sprintf("out_ch%02d.wav", ch)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				// the stems hold as much audio as the full mix, which only has the song's details on top
+				if stem.Size() == 0 || stem.Size() > master.Size() || master.Size()-stem.Size() > 1024 {
+					t.Fatalf("stem %d is %d bytes, but the full mix is %d bytes", ch, stem.Size(), master.Size())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package play
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gotracker/gotracker/internal/dsp"
+	"github.com/gotracker/gotracker/internal/playlist"
+	"github.com/gotracker/playback/mixing"
+	"github.com/gotracker/playback/mixing/volume"
+	playbackOutput "github.com/gotracker/playback/output"
+	playbackFeature "github.com/gotracker/playback/player/feature"
+	"github.com/gotracker/playback/player/machine"
+	"github.com/gotracker/playback/player/render"
+	"github.com/gotracker/playback/player/sampler"
+	"github.com/gotracker/playback/song"
+)
+
+// songLoader loads a playlist entry in the background
+type songLoader struct {
+	done chan struct{}
+	song *loadedSong
+	err  error
+}
+
+func loadSongAsync(entry *playlist.Song, features []playbackFeature.Feature) *songLoader {
+	l := songLoader{
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(l.done)
+		l.song, l.err = loadSong(entry, features)
+	}()
+	return &l
+}
+
+// wait waits until the song is loaded
+func (l *songLoader) wait() (*loadedSong, error) {
+	<-l.done
+	return l.song, l.err
+}
+
+// transition carries a song into the next one in the playlist.
+// The next song is loaded while the current one plays, so that it is ready to go as soon as it is needed.
+type transition struct {
+	mode    playlist.TransitionMode
+	length  time.Duration
+	loader  *songLoader
+	prepare func(ls *loadedSong) (*preparedSong, error)
+
+	// the next song, once it has started mixing in to the current one
+	incoming        *preparedSong
+	incomingDone    bool
+	incomingElapsed int
+	pending         mixing.MixBuffer
+	pendingUserdata any
+	lastSamplesLen  int
+	lastUserdata    any
+}
+
+func newTransition(t playlist.Transition, loader *songLoader, prepare func(ls *loadedSong) (*preparedSong, error)) *transition {
+	mode, _ := t.Mode.Get()
+	length, _ := t.Length.Get()
+	return &transition{
+		mode:    mode,
+		length:  length,
+		loader:  loader,
+		prepare: prepare,
+	}
+}
+
+// wrap returns a machine which plays `m` and then transitions out of it
+func (t *transition) wrap(m machine.MachineTicker) machine.MachineTicker {
+	if t.incoming != nil {
+		// the machine is being replaced mid-transition, so start the next song over
+		t.incoming = nil
+		t.incomingDone = false
+		t.incomingElapsed = 0
+		t.pending = nil
+	}
+
+	return &transitionMachine{
+		MachineTicker: m,
+		t:             t,
+	}
+}
+
+// handover returns the next song, picking up where it left off if it has already started
+func (t *transition) handover() (*preparedSong, error) {
+	if t.incoming == nil {
+		ls, err := t.loader.wait()
+		if err != nil {
+			return nil, err
+		}
+		return t.prepare(ls)
+	}
+
+	in := *t.incoming
+	in.machine = &pendingMachine{
+		MachineTicker: in.machine,
+		pending:       t.pending,
+		userdata:      quietUserdata(t.pendingUserdata),
+		done:          t.incomingDone,
+	}
+	return &in, nil
+}
+
+// mixIncoming mixes the next song, fading it in, into the premix of the current song
+func (t *transition) mixIncoming(s *sampler.Sampler, premix *playbackOutput.PremixData) (*playbackOutput.PremixData, error) {
+	if t.incoming == nil {
+		ls, err := t.loader.wait()
+		if err != nil {
+			return nil, err
+		}
+		if t.incoming, err = t.prepare(ls); err != nil {
+			return nil, err
+		}
+	}
+
+	channels := s.Mixer().Channels
+	for len(t.pending) < premix.SamplesLen && !t.incomingDone {
+		var in *playbackOutput.PremixData
+		fs := *s
+		fs.OnGenerate = func(p *playbackOutput.PremixData) {
+			in = p
+		}
+		if err := t.incoming.machine.Tick(&fs); err != nil {
+			if !errors.Is(err, song.ErrStopSong) {
+				return nil, err
+			}
+			t.incomingDone = true
+		}
+		if in != nil {
+			t.pending = append(t.pending, dsp.Flatten(channels, in)...)
+			t.pendingUserdata = in.Userdata
+		}
+	}
+
+	length := int(t.length.Seconds() * float64(s.SampleRate))
+	data := dsp.Flatten(channels, premix)
+	n := min(len(data), len(t.pending))
+	for i, in := range t.pending[:n] {
+		gain := float32(1)
+		if t.incomingElapsed+i < length {
+			gain = float32(t.incomingElapsed+i) / float32(length)
+		}
+		data[i].Accumulate(in.Apply(volume.Volume(gain)))
+	}
+	t.pending = t.pending[n:]
+	t.incomingElapsed += premix.SamplesLen
+
+	return dsp.NewFlatPremix(channels, data, premix.Userdata), nil
+}
+
+// transitionMachine plays a song, then transitions out of it
+type transitionMachine struct {
+	machine.MachineTicker
+	t       *transition
+	ended   bool
+	silence int
+}
+
+// Tick runs a single tick of the song or, once the song has ended, of the transition
+func (m *transitionMachine) Tick(s *sampler.Sampler) error {
+	t := m.t
+	if m.ended {
+		return m.tickSilence(s)
+	}
+
+	if s == nil {
+		return m.MachineTicker.Tick(s)
+	}
+
+	var premix *playbackOutput.PremixData
+	fs := *s
+	fs.OnGenerate = func(p *playbackOutput.PremixData) {
+		premix = p
+	}
+	err := m.MachineTicker.Tick(&fs)
+	if premix != nil {
+		t.lastSamplesLen = premix.SamplesLen
+		t.lastUserdata = premix.Userdata
+		if f, ok := asMachine[*fadeoutMachine](m.MachineTicker); ok && f.fading && t.mode == playlist.TransitionCrossfade {
+			var mixErr error
+			if premix, mixErr = t.mixIncoming(s, premix); mixErr != nil {
+				return mixErr
+			}
+		}
+		if s.OnGenerate != nil {
+			s.OnGenerate(premix)
+		}
+	}
+
+	if err != nil && errors.Is(err, song.ErrStopSong) && t.mode == playlist.TransitionSilence {
+		m.ended = true
+		return nil
+	}
+	return err
+}
+
+// tickSilence outputs a tick's worth of silence, until the silence is over
+func (m *transitionMachine) tickSilence(s *sampler.Sampler) error {
+	t := m.t
+	length := int(t.length.Seconds() * float64(s.SampleRate))
+	if m.silence >= length {
+		return song.ErrStopSong
+	}
+
+	samples := t.lastSamplesLen
+	if samples <= 0 {
+		samples = s.SampleRate / 50
+	}
+	samples = min(samples, length-m.silence)
+	m.silence += samples
+
+	if s.OnGenerate != nil {
+		channels := s.Mixer().Channels
+		s.OnGenerate(dsp.NewFlatPremix(channels, dsp.NewSilence(channels, samples), quietUserdata(t.lastUserdata)))
+	}
+	return nil
+}
+
+// Unwrap returns the machine of the song being transitioned out of
+func (m *transitionMachine) Unwrap() machine.MachineTicker {
+	return m.MachineTicker
+}
+
+// transitionSeeker makes sure that machines created by seeking still transition into the next song
+type transitionSeeker struct {
+	Seeker
+	t *transition
+}
+
+// SeekPosition returns a machine that starts at the provided order and row
+func (s transitionSeeker) SeekPosition(order, row int) (machine.MachineTicker, error) {
+	m, err := s.Seeker.SeekPosition(order, row)
+	if err != nil {
+		return nil, err
+	}
+	return s.t.wrap(m), nil
+}
+
+// SeekTime returns a machine that starts at the row being played at time `t`
+func (s transitionSeeker) SeekTime(t time.Duration) (machine.MachineTicker, error) {
+	m, err := s.Seeker.SeekTime(t)
+	if err != nil {
+		return nil, err
+	}
+	return s.t.wrap(m), nil
+}
+
+// pendingMachine outputs whatever audio was left over from a crossfade before continuing on with the song
+type pendingMachine struct {
+	machine.MachineTicker
+	pending  mixing.MixBuffer
+	userdata any
+	done     bool
+}
+
+// Tick runs a single tick of the song, starting with the left over audio
+func (m *pendingMachine) Tick(s *sampler.Sampler) error {
+	if len(m.pending) > 0 && s != nil {
+		if s.OnGenerate != nil {
+			s.OnGenerate(dsp.NewFlatPremix(s.Mixer().Channels, m.pending, m.userdata))
+		}
+		m.pending = nil
+		return nil
+	}
+
+	if m.done {
+		return song.ErrStopSong
+	}
+	return m.MachineTicker.Tick(s)
+}
+
+// Unwrap returns the machine of the song
+func (m *pendingMachine) Unwrap() machine.MachineTicker {
+	return m.MachineTicker
+}
+
+// quietUserdata returns a copy of the row render `userdata` without any row text, so that it isn't displayed twice
+func quietUserdata(userdata any) any {
+	if row, ok := userdata.(*render.RowRender); ok && row != nil {
+		return &render.RowRender{
+			Order: row.Order,
+			Row:   row.Row,
+			Tick:  row.Tick,
+		}
+	}
+	return userdata
+}
@@ -0,0 +1,28 @@
+package play
+
+import (
+	"github.com/gotracker/playback/player/machine"
+)
+
+// machineUnwrapper is implemented by machines that add behavior on top of another machine
+type machineUnwrapper interface {
+	Unwrap() machine.MachineTicker
+}
+
+// asMachine finds the first machine in a chain of wrapped machines that is a T
+func asMachine[T any](m machine.MachineTicker) (T, bool) {
+	for m != nil {
+		if v, ok := m.(T); ok {
+			return v, true
+		}
+
+		w, ok := m.(machineUnwrapper)
+		if !ok {
+			break
+		}
+		m = w.Unwrap()
+	}
+
+	var empty T
+	return empty, false
+}
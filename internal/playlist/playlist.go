@@ -21,6 +21,8 @@ type Playlist struct {
 	lastPlayedMaxSize int
 	loop              optional.Value[bool]
 	randomized        optional.Value[bool]
+	transition        Transition
+	master            Master
 }
 
 func New() *Playlist {
@@ -33,8 +35,10 @@ func (p *Playlist) Reset() {
 }
 
 type yamlPlaylist struct {
-	Version string `yaml:"version,omitempty"`
-	Songs   []Song `yaml:"list,omitempty"`
+	Version    string     `yaml:"version,omitempty"`
+	Transition Transition `yaml:"transition,omitempty"`
+	Master     Master     `yaml:"master,omitempty"`
+	Songs      []Song     `yaml:"list,omitempty"`
 }
 
 const yamlPlaylistCurrentVersion string = "1.0"
@@ -61,8 +65,23 @@ func ReadYAML(r io.Reader, basepath string) (*Playlist, error) {
 		}
 	}
 
+	if err := pl.Transition.validate(); err != nil {
+		return nil, err
+	}
+	if err := pl.Master.validate(); err != nil {
+		return nil, err
+	}
+
 	p := New()
+	p.SetTransition(pl.Transition)
+	p.SetMaster(pl.Master)
 	for _, s := range pl.Songs {
+		if err := s.Transition.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Filepath, err)
+		}
+		if err := s.Master.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Filepath, err)
+		}
 		s.Filepath = filepath.Join(basepath, s.Filepath)
 		if s.End.Order.IsSet() {
 			if !s.End.Row.IsSet() {
@@ -80,8 +99,10 @@ func (p *Playlist) WriteYAML(w io.Writer) error {
 	defer y.Close()
 
 	pl := yamlPlaylist{
-		Version: yamlPlaylistCurrentVersion,
-		Songs:   p.songs,
+		Version:    yamlPlaylistCurrentVersion,
+		Transition: p.transition,
+		Master:     p.master,
+		Songs:      p.songs,
 	}
 
 	return y.Encode(&pl)
@@ -115,6 +136,30 @@ func (p Playlist) IsRandomized() bool {
 	return false
 }
 
+func (p *Playlist) SetTransition(value Transition) {
+	p.transition = value
+}
+
+// GetTransition returns the transition to use when going from song `s` to the next one
+func (p Playlist) GetTransition(s *Song) Transition {
+	if s != nil && s.Transition.Mode.IsSet() {
+		return s.Transition
+	}
+	return p.transition
+}
+
+func (p *Playlist) SetMaster(value Master) {
+	p.master = value
+}
+
+// GetMaster returns the mastering to use for song `s`
+func (p Playlist) GetMaster(s *Song) Master {
+	if s == nil {
+		return p.master
+	}
+	return s.Master.Merge(p.master)
+}
+
 func (p *Playlist) MarkPlayed(s *Song) {
 	if !p.IsRandomized() {
 		// this is only useful if in randomized mode
@@ -1,6 +1,9 @@
 package playlist
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/heucuva/optional"
 )
 
@@ -10,13 +13,18 @@ type Position struct {
 }
 
 type Song struct {
-	Filepath string              `yaml:"file,omitempty"`
-	Start    Position            `yaml:"start,omitempty"`
-	End      Position            `yaml:"end,omitempty"`
-	Loop     Loop                `yaml:"loop,omitempty"`
-	Fadeout  Fadeout             `yaml:"fadeout,omitempty"`
-	Tempo    optional.Value[int] `yaml:"tempo,omitempty"`
-	BPM      optional.Value[int] `yaml:"bpm,omitempty"`
+	Filepath   string              `yaml:"file,omitempty"`
+	Artist     string              `yaml:"artist,omitempty"` // who made the song (tracker formats don't record it)
+	Start      Position            `yaml:"start,omitempty"`
+	End        Position            `yaml:"end,omitempty"`
+	Loop       Loop                `yaml:"loop,omitempty"`
+	Fadeout    Fadeout             `yaml:"fadeout,omitempty"`
+	Transition Transition          `yaml:"transition,omitempty"` // how this song transitions into the next one (overrides the playlist transition)
+	Master     Master              `yaml:"master,omitempty"`     // how the mix of this song is mastered (overrides the playlist mastering)
+	Mute       []int               `yaml:"mute,omitempty,flow"`  // channels (1-based) to mute
+	Solo       []int               `yaml:"solo,omitempty,flow"`  // channels (1-based) to solo - when set, all other channels are muted
+	Tempo      optional.Value[int] `yaml:"tempo,omitempty"`
+	BPM        optional.Value[int] `yaml:"bpm,omitempty"`
 }
 
 type Loop struct {
@@ -32,5 +40,64 @@ func NewLoopForever() optional.Value[int] {
 }
 
 type Fadeout struct {
-	Length optional.Value[int] `yaml:"length,omitempty" default:"0"` // when Song.End (and Loop.Count) is reached, this is the number of ticks to fadeout over
+	Length optional.Value[int]           `yaml:"length,omitempty" default:"0"` // when Song.End (and Loop.Count) is reached, this is the number of ticks to fadeout over
+	Time   optional.Value[time.Duration] `yaml:"time,omitempty"`               // when Song.End (and Loop.Count) is reached, this is the amount of time to fadeout over (only used when Length is not set)
+}
+
+type TransitionMode string
+
+const (
+	TransitionGapless   = TransitionMode("gapless")   // the next song starts immediately after this one ends
+	TransitionCrossfade = TransitionMode("crossfade") // the next song fades in while this one fades out
+	TransitionSilence   = TransitionMode("silence")   // the next song starts after a period of silence
+)
+
+func (m TransitionMode) IsValid() bool {
+	switch m {
+	case TransitionGapless, TransitionCrossfade, TransitionSilence:
+		return true
+	default:
+		return false
+	}
+}
+
+type Transition struct {
+	Mode   optional.Value[TransitionMode] `yaml:"mode,omitempty"`
+	Length optional.Value[time.Duration]  `yaml:"length,omitempty"` // length of the crossfade or silence
+}
+
+func (t Transition) validate() error {
+	if mode, ok := t.Mode.Get(); ok && !mode.IsValid() {
+		return fmt.Errorf("unknown transition mode %q", mode)
+	}
+	return nil
+}
+
+type Master struct {
+	DCFilter       optional.Value[bool]          `yaml:"dcfilter,omitempty"`       // remove any DC offset
+	Limiter        optional.Value[bool]          `yaml:"limiter,omitempty"`        // run the look-ahead peak limiter
+	LimiterCeiling optional.Value[float64]       `yaml:"limiterceiling,omitempty"` // highest level the limiter lets through (in dBFS)
+	LimiterRelease optional.Value[time.Duration] `yaml:"limiterrelease,omitempty"` // how long the limiter takes to let go after a peak
+	SoftClip       optional.Value[bool]          `yaml:"softclip,omitempty"`       // round off peaks instead of clipping them
+}
+
+// Merge returns the mastering of `m`, with anything it doesn't set taken from `defaults`
+func (m Master) Merge(defaults Master) Master {
+	return Master{
+		DCFilter:       optional.Coalesce(m.DCFilter, defaults.DCFilter),
+		Limiter:        optional.Coalesce(m.Limiter, defaults.Limiter),
+		LimiterCeiling: optional.Coalesce(m.LimiterCeiling, defaults.LimiterCeiling),
+		LimiterRelease: optional.Coalesce(m.LimiterRelease, defaults.LimiterRelease),
+		SoftClip:       optional.Coalesce(m.SoftClip, defaults.SoftClip),
+	}
+}
+
+func (m Master) validate() error {
+	if ceiling, ok := m.LimiterCeiling.Get(); ok && ceiling > 0 {
+		return fmt.Errorf("limiter ceiling %v dBFS is over full scale", ceiling)
+	}
+	if release, ok := m.LimiterRelease.Get(); ok && release < 0 {
+		return fmt.Errorf("limiter release %v is negative", release)
+	}
+	return nil
 }
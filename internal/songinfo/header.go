@@ -0,0 +1,224 @@
+package songinfo
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/gotracker/goaudiofile/music/tracked/it"
+	"github.com/gotracker/goaudiofile/music/tracked/mod"
+	"github.com/gotracker/goaudiofile/music/tracked/s3m"
+	"github.com/gotracker/goaudiofile/music/tracked/xm"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// header is the metadata of a song that only its file format knows about
+type header struct {
+	format      string
+	tracker     string
+	patterns    int
+	instruments []string
+	samples     []string
+	message     string
+}
+
+type headerReader func(data []byte) (*header, error)
+
+// DetectFormat returns the name of the file format of the song in `data`
+func DetectFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("IMPM")):
+		return "IT"
+	case bytes.HasPrefix(data, []byte("Extended Module: ")):
+		return "XM"
+	case len(data) >= 0x30 && string(data[0x2C:0x30]) == "SCRM":
+		return "S3M"
+	default:
+		return "MOD"
+	}
+}
+
+// getHeaderReader returns the reader for the file format of `data`
+func getHeaderReader(data []byte) headerReader {
+	switch DetectFormat(data) {
+	case "IT":
+		return readITHeader
+	case "XM":
+		return readXMHeader
+	case "S3M":
+		return readS3MHeader
+	default:
+		return readMODHeader
+	}
+}
+
+func readS3MHeader(data []byte) (*header, error) {
+	f, err := s3m.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	h := header{
+		format:   "S3M",
+		tracker:  s3mTrackerName(f.Head.TrackerVersion),
+		patterns: int(f.Head.PatternCount),
+	}
+
+	for _, inst := range f.Instruments {
+		var name string
+		if a, ok := inst.Ancillary.(interface{ GetSampleName() string }); ok {
+			name = a.GetSampleName()
+		}
+		h.samples = append(h.samples, CleanName(name))
+	}
+
+	return &h, nil
+}
+
+func s3mTrackerName(version uint16) string {
+	major, minor := (version>>8)&0x0F, version&0xFF
+	switch version >> 12 {
+	case 1:
+		return fmt.Sprintf("Scream Tracker %d.%02x", major, minor)
+	case 2:
+		return fmt.Sprintf("Imago Orpheus %d.%02x", major, minor)
+	case 3:
+		return fmt.Sprintf("Impulse Tracker %d.%02x", major, minor)
+	case 4:
+		return "Schism Tracker"
+	case 5:
+		return "OpenMPT"
+	default:
+		return fmt.Sprintf("unknown (%0.4x)", version)
+	}
+}
+
+func readITHeader(data []byte) (*header, error) {
+	f, err := it.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	h := header{
+		format:   "IT",
+		tracker:  itTrackerName(f.Head.TrackerVersion),
+		patterns: int(f.Head.PatternCount),
+	}
+
+	for _, inst := range f.Instruments {
+		var name string
+		switch i := inst.(type) {
+		case *it.IMPIInstrument:
+			name = i.GetName()
+		case *it.IMPIInstrumentOld:
+			name = i.GetName()
+		}
+		h.instruments = append(h.instruments, CleanName(name))
+	}
+
+	for _, samp := range f.Samples {
+		h.samples = append(h.samples, CleanName(samp.Header.GetName()))
+	}
+
+	if f.Head.SpecialFlags.IsMessageAttached() {
+		start := f.Head.MessageOffset.Offset()
+		end := start + int(f.Head.MessageLength)
+		if start > 0 && end <= len(data) {
+			// Impulse Tracker ends its message lines with a carriage return
+			msg := strings.ReplaceAll(string(data[start:end]), "\r", "\n")
+			h.message = cleanMessage(msg)
+		}
+	}
+
+	return &h, nil
+}
+
+func itTrackerName(version uint16) string {
+	switch version >> 12 {
+	case 0:
+		return fmt.Sprintf("Impulse Tracker %x.%02x", version>>8, version&0xFF)
+	case 1:
+		return "Schism Tracker"
+	case 5:
+		return "OpenMPT"
+	default:
+		return fmt.Sprintf("unknown (%0.4x)", version)
+	}
+}
+
+func readXMHeader(data []byte) (*header, error) {
+	f, err := xm.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	h := header{
+		format:   "XM",
+		patterns: int(f.Head.NumPatterns),
+	}
+
+	tracker := CleanName(string(bytes.TrimRight(f.Head.TrackerName[:], "\x00")))
+	h.tracker = fmt.Sprintf("%s (format %d.%02d)", tracker, f.Head.VersionNumber>>8, f.Head.VersionNumber&0xFF)
+
+	for _, inst := range f.Instruments {
+		h.instruments = append(h.instruments, CleanName(inst.GetName()))
+		for _, samp := range inst.Samples {
+			h.samples = append(h.samples, CleanName(samp.GetName()))
+		}
+	}
+
+	return &h, nil
+}
+
+func readMODHeader(data []byte) (*header, error) {
+	f, err := mod.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	h := header{
+		format:   "MOD",
+		tracker:  modTrackerName(string(f.Head.Sig[:])),
+		patterns: len(f.Patterns),
+	}
+
+	for _, inst := range f.Head.Instrument {
+		h.samples = append(h.samples, CleanName(inst.GetName()))
+	}
+
+	return &h, nil
+}
+
+func modTrackerName(sig string) string {
+	switch {
+	case sig == "M.K." || sig == "M!K!":
+		return fmt.Sprintf("ProTracker (%s)", sig)
+	case strings.HasPrefix(sig, "FLT") || strings.HasPrefix(sig, "EXO"):
+		return fmt.Sprintf("StarTrekker (%s)", sig)
+	default:
+		return fmt.Sprintf("FastTracker (%s)", sig)
+	}
+}
+
+// CleanName trims a fixed-length name field of a song, replacing any unprintable characters with spaces.
+// The trackers ran on DOS, so the text is decoded from its character set (code page 437).
+func CleanName(s string) string {
+	if decoded, err := charmap.CodePage437.NewDecoder().String(s); err == nil {
+		s = decoded
+	}
+	return strings.TrimRight(strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7F {
+			return ' '
+		}
+		return r
+	}, s), " ")
+}
+
+// cleanMessage cleans up a song message, keeping its line breaks
+func cleanMessage(msg string) string {
+	lines := strings.Split(strings.TrimRight(msg, "\x00"), "\n")
+	for i, line := range lines {
+		lines[i] = CleanName(line)
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,100 @@
+package songinfo
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gotracker/playback/format"
+	playbackFeature "github.com/gotracker/playback/player/feature"
+	"github.com/gotracker/playback/player/machine/settings"
+
+	"github.com/gotracker/gotracker/internal/play"
+)
+
+// Info is the metadata of a song
+type Info struct {
+	Filename     string   `json:"filename" yaml:"filename"`
+	Title        string   `json:"title" yaml:"title"`
+	Format       string   `json:"format" yaml:"format"`
+	Tracker      string   `json:"tracker" yaml:"tracker"`
+	Orders       int      `json:"orders" yaml:"orders"`
+	Patterns     int      `json:"patterns" yaml:"patterns"`
+	Channels     int      `json:"channels" yaml:"channels"`
+	Instruments  []string `json:"instruments,omitempty" yaml:"instruments,omitempty"`
+	Samples      []string `json:"samples,omitempty" yaml:"samples,omitempty"`
+	InitialTempo int      `json:"initial_tempo" yaml:"initial_tempo"`
+	InitialBPM   int      `json:"initial_bpm" yaml:"initial_bpm"`
+	Message      string   `json:"message,omitempty" yaml:"message,omitempty"`
+
+	Duration time.Duration `json:"-" yaml:"-"`
+	// DurationSeconds is the estimated duration of the song, in seconds
+	DurationSeconds float64 `json:"duration_seconds" yaml:"duration_seconds"`
+	// Looping is set when the song loops forever, in which case the duration only covers its first time through
+	Looping   bool       `json:"looping" yaml:"looping"`
+	Positions []Position `json:"positions,omitempty" yaml:"positions,omitempty"`
+}
+
+// Position is the time at which playback arrives at an order of the song
+type Position struct {
+	Order   int     `json:"order" yaml:"order"`
+	Row     int     `json:"row" yaml:"row"`
+	Seconds float64 `json:"seconds" yaml:"seconds"`
+}
+
+// Load loads the song in `filename` and gathers up its metadata
+func Load(filename string) (*Info, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	songData, songFmt, err := format.Load(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not load song: %w", err)
+	}
+
+	h, err := getHeaderReader(data)(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not read song header: %w", err)
+	}
+
+	var us settings.UserSettings
+	us.Reset()
+	if err := songFmt.ConvertFeaturesToSettings(&us, []playbackFeature.Feature{playbackFeature.SongLoop{Count: 0}}); err != nil {
+		return nil, fmt.Errorf("could not configure playback settings: %w", err)
+	}
+
+	duration, err := play.EstimateDuration(songData, us)
+	if err != nil {
+		return nil, fmt.Errorf("could not estimate song duration: %w", err)
+	}
+
+	info := Info{
+		Filename:        filename,
+		Title:           CleanName(songData.GetName()),
+		Format:          h.format,
+		Tracker:         h.tracker,
+		Orders:          len(songData.GetOrderList()),
+		Patterns:        h.patterns,
+		Channels:        songData.GetNumChannels(),
+		Instruments:     h.instruments,
+		Samples:         h.samples,
+		InitialTempo:    songData.GetInitialTempo(),
+		InitialBPM:      songData.GetInitialBPM(),
+		Message:         h.message,
+		Duration:        duration.Total,
+		DurationSeconds: duration.Total.Seconds(),
+		Looping:         duration.Looping,
+	}
+
+	for _, pos := range duration.Positions {
+		info.Positions = append(info.Positions, Position{
+			Order:   pos.Order,
+			Row:     pos.Row,
+			Seconds: pos.Time.Seconds(),
+		})
+	}
+
+	return &info, nil
+}